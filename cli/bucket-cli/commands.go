@@ -0,0 +1,594 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/output"
+	"golang.org/x/term"
+)
+
+// Exit codes. Scripts can rely on these to tell failures apart.
+const (
+	exitOK          = 0
+	exitError       = 1   // Anything not covered below
+	exitUsage       = 2   // Bad flags or arguments
+	exitAuth        = 3   // Not logged in, bad credentials or subscription
+	exitNotFound    = 4   // No such file, or it expired
+	exitQuota       = 5   // Storage quota exceeded
+	exitNetwork     = 6   // Server unreachable or the connection dropped
+	exitVerify      = 7   // Checksum, upload verification or decryption failed
+	exitInterrupted = 130 // Ctrl-C, same as a shell would report
+)
+
+var exitCode = exitOK
+
+type command struct {
+	name    string
+	args    string // Positional arguments, for usage lines
+	summary string
+	minArgs int
+	maxArgs int // -1 for no limit
+
+	completeIDs bool // First argument is a file ID, offer cached ones on <Tab>
+
+	// setup registers the command's flags and returns what runs it once
+	// they are parsed
+	setup func(fs *flag.FlagSet) func(ctx context.Context, cfg *config.Config, args []string)
+}
+
+var commands = []*command{
+	{
+		name: "login", summary: "Login, or save an existing API key with --api-key-stdin/--api-key-file",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			var opts loginOptions
+			fs.BoolVar(&opts.keyStdin, "api-key-stdin", false, "read an API key from stdin instead of prompting")
+			fs.StringVar(&opts.keyFile, "api-key-file", "", "read an API key from the file at `path`")
+
+			return func(ctx context.Context, cfg *config.Config, _ []string) {
+				if opts.keyStdin && opts.keyFile != "" {
+					failWith(exitUsage, "Use either --api-key-stdin or --api-key-file", nil)
+					return
+				}
+				handleLogin(ctx, cfg, opts)
+			}
+		},
+	},
+	{
+		name: "logout", summary: "Logout",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(ctx context.Context, cfg *config.Config, _ []string) { handleLogout(ctx, cfg) }
+		},
+	},
+	{
+		name: "account", summary: "View account info",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(ctx context.Context, cfg *config.Config, _ []string) { handleAccount(ctx, cfg) }
+		},
+	},
+	{
+		name: "push", args: "<file|dir|->...", minArgs: 1, maxArgs: -1,
+		summary: "Upload a file, a tar of dirs/many files, or stdin (-)",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			var opts pushOptions
+			fs.BoolVar(&opts.resume, "resume", false, "keep progress on interrupt and continue it later")
+			fs.BoolVar(&opts.encrypt, "encrypt", false, "encrypt before upload, the key is added to the secret")
+			fs.Var((*stringList)(&opts.recipients), "to", "encrypt for a recipient's `public key` (repeatable)")
+			fs.BoolVar(&opts.compress, "compress", false, "zstd-compress directory/multi-file archives")
+			fs.StringVar(&opts.name, "name", "", "store under `name`, for stdin and archives")
+			fs.IntVar(&opts.maxDownloads, "max-downloads", 0, "allow at most `n` downloads, then delete")
+			fs.BoolVar(&opts.burn, "burn", false, "delete after the first successful download")
+			fs.Func("expires", "expire after a `duration` (2h, 7d) or at a date (2026-12-01)", func(v string) (err error) {
+				opts.expires, err = parseExpiry(v, time.Now())
+				opts.expiresFlag = v
+				return err
+			})
+
+			return func(ctx context.Context, cfg *config.Config, files []string) {
+				if opts.maxDownloads < 0 {
+					failWith(exitUsage, "--max-downloads must be 0 (unlimited) or more", nil)
+					return
+				}
+				if opts.burn && opts.maxDownloads > 1 {
+					failWith(exitUsage, "--burn allows a single download, drop --max-downloads", nil)
+					return
+				}
+				if files[0] == "-" {
+					if len(files) > 1 {
+						failWith(exitUsage, "Push from stdin (-) takes no other files", nil)
+						return
+					}
+					name := opts.name
+					if name == "" {
+						name = "stdin"
+					}
+					pushStream(ctx, cfg, name, os.Stdin, opts)
+				} else if stat, err := os.Stat(files[0]); len(files) == 1 && err == nil && stat.Mode().IsRegular() {
+					handlePush(ctx, cfg, files[0], opts)
+				} else {
+					handlePushArchive(ctx, cfg, files, opts)
+				}
+			}
+		},
+	},
+	{
+		name: "pull", args: "<bURL>", minArgs: 1, maxArgs: 1, completeIDs: true,
+		summary: "Download a file",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			var opts pullOptions
+			fs.IntVar(&opts.connections, "connections", 1, "fetch large files over `n` parallel connections")
+			fs.BoolVar(&opts.extract, "extract", false, "unpack a pushed directory/multi-file archive")
+			fs.StringVar(&opts.output, "o", "", "save the file as `path`, or - to write it to stdout (--output picks the result format)")
+
+			return func(ctx context.Context, cfg *config.Config, args []string) {
+				if opts.connections < 1 {
+					failWith(exitUsage, fmt.Sprint("Invalid --connections: ", opts.connections), nil)
+					return
+				}
+				if opts.output == "-" && opts.extract {
+					failWith(exitUsage, "--extract cannot be combined with -o -", nil)
+					return
+				}
+				if opts.output == "-" {
+					if printer.Structured() {
+						failWith(exitUsage, "--json/--output cannot be combined with -o -", nil)
+						return
+					}
+					// stdout carries the file, so messages go to stderr
+					ui = os.Stderr
+					printer.UI = ui
+					if !globals.quiet {
+						status = ui
+					}
+				}
+				handlePull(ctx, cfg, args[0], opts)
+			}
+		},
+	},
+	{
+		name: "list", summary: "List uploaded files",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(ctx context.Context, cfg *config.Config, _ []string) { handleList(ctx, cfg) }
+		},
+	},
+	{
+		name: "info", args: "<id>", minArgs: 1, maxArgs: 1, completeIDs: true,
+		summary: "Show everything known about a file",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(ctx context.Context, cfg *config.Config, args []string) { handleInfo(ctx, cfg, args[0]) }
+		},
+	},
+	{
+		name: "rotate", args: "<id>", minArgs: 1, maxArgs: 1, completeIDs: true,
+		summary: "Issue a new secret for a file, the old one stops working",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			var newCode bool
+			fs.BoolVar(&newCode, "new-id", false, "also replace the file's tiny code (bID)")
+			return func(ctx context.Context, cfg *config.Config, args []string) { handleRotate(ctx, cfg, args[0], newCode) }
+		},
+	},
+	{
+		name: "del", args: "<id>", minArgs: 1, maxArgs: 1, completeIDs: true,
+		summary: "Delete file",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(ctx context.Context, cfg *config.Config, args []string) { handleDelete(ctx, cfg, args[0]) }
+		},
+	},
+	{
+		name: "extend", args: "<id>", minArgs: 1, maxArgs: 1, completeIDs: true,
+		summary: "Keep a file around longer",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			var by time.Duration
+			var until time.Time
+			fs.Func("by", "push the expiry out by `duration` (12h, 3d, 1w)", func(v string) (err error) {
+				by, err = parseDuration(v)
+				if err == nil && by <= 0 {
+					err = fmt.Errorf("duration must be positive")
+				}
+				return err
+			})
+			fs.Func("until", "expire at `date` (2026-12-01) or after a duration from now", func(v string) (err error) {
+				until, err = parseExpiry(v, time.Now())
+				return err
+			})
+
+			return func(ctx context.Context, cfg *config.Config, args []string) {
+				if (by == 0) == until.IsZero() {
+					failWith(exitUsage, "extend needs exactly one of --by or --until", nil)
+					return
+				}
+				handleExtend(ctx, cfg, args[0], by, until)
+			}
+		},
+	},
+	{
+		name: "keygen", summary: "Create an identity for receiving encrypted files",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(context.Context, *config.Config, []string) { handleKeygen() }
+		},
+	},
+	{
+		name: "profile", args: "<list|use|add|remove> [name]", minArgs: 1, maxArgs: 2,
+		summary: "Manage named accounts (add takes --api-base to save a server)",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(_ context.Context, _ *config.Config, args []string) { handleProfile(args) }
+		},
+	},
+	{
+		name: "keys", args: "<create|list|revoke|rename> [key] [new name]", minArgs: 1, maxArgs: 3,
+		summary: "Manage API keys (create takes --name and --scopes for CI)",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			var opts keyOptions
+			fs.StringVar(&opts.name, "name", "", "label the key `name`, e.g. ci-release")
+			fs.Func("scopes", "limit the key to `list` of scopes, comma separated (e.g. push,pull)", func(v string) error {
+				for _, scope := range strings.Split(v, ",") {
+					if scope = strings.TrimSpace(scope); scope != "" {
+						opts.scopes = append(opts.scopes, scope)
+					}
+				}
+				return nil
+			})
+			return func(ctx context.Context, cfg *config.Config, args []string) { handleKeys(ctx, cfg, args, opts) }
+		},
+	},
+	{
+		name: "devices", args: "<list|revoke> [device]", minArgs: 1, maxArgs: 2,
+		summary: "List devices logged in to the account, or sign one out",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(ctx context.Context, cfg *config.Config, args []string) { handleDevices(ctx, cfg, args) }
+		},
+	},
+	{
+		name: "config", args: "show", minArgs: 1, maxArgs: 1,
+		summary: "Show the settings in effect (--origin says where each came from)",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			var showOrigin bool
+			fs.BoolVar(&showOrigin, "origin", false, "show which flag, variable or file set each value")
+			return func(_ context.Context, cfg *config.Config, args []string) { handleConfig(cfg, args[0], showOrigin) }
+		},
+	},
+	{
+		name: "completion", args: "<bash|zsh|fish|powershell>", minArgs: 1, maxArgs: 1,
+		summary: "Print a shell completion script",
+		setup: func(fs *flag.FlagSet) func(context.Context, *config.Config, []string) {
+			return func(_ context.Context, _ *config.Config, args []string) { handleCompletion(args[0]) }
+		},
+	},
+}
+
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// globalOptions are accepted before the command name or among its flags
+type globalOptions struct {
+	configPath string
+	profile    string
+	apiBase    string
+	quiet      bool
+	verbose    bool
+	json       bool
+	output     string
+
+	connectTimeout time.Duration
+	stallTimeout   time.Duration
+}
+
+var globals = globalOptions{
+	output:         string(output.Text),
+	connectTimeout: api.DefaultTimeouts.Connect,
+	stallTimeout:   api.DefaultTimeouts.Stall,
+}
+
+var globalNames = map[string]bool{
+	"config": true, "profile": true, "api-base": true, "quiet": true, "verbose": true, "json": true, "output": true,
+	"connect-timeout": true, "stall-timeout": true,
+}
+
+// register adds the global flags to fs. Current values become the defaults
+// so a second FlagSet does not reset what an earlier one parsed.
+func (g *globalOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.configPath, "config", g.configPath, "use the config file at `path`")
+	fs.StringVar(&g.profile, "profile", g.profile, "use the account and settings saved as `name`")
+	fs.StringVar(&g.apiBase, "api-base", g.apiBase, "talk to the API at `url` for this run")
+	fs.BoolVar(&g.quiet, "quiet", g.quiet, "print only results and errors, no progress")
+	fs.BoolVar(&g.verbose, "verbose", g.verbose, "report retried requests on stderr")
+	fs.BoolVar(&g.json, "json", g.json, "print results as JSON on stdout")
+	fs.StringVar(&g.output, "output", g.output, "print results as text, json, yaml or table (`format`)")
+	fs.DurationVar(&g.connectTimeout, "connect-timeout", g.connectTimeout, "give up connecting to a server after `duration`, 0 to wait forever")
+	fs.DurationVar(&g.stallTimeout, "stall-timeout", g.stallTimeout, "retry a transfer that moved no bytes for `duration`, 0 to never")
+}
+
+// stringList is a flag that may be repeated
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// run parses the command line, runs the command and returns the exit code
+func run(args []string) int {
+	if len(args) > 0 && args[0] == "__complete" {
+		return runComplete(args[1:])
+	}
+
+	top := flag.NewFlagSet("bucket", flag.ContinueOnError)
+	top.SetOutput(io.Discard)
+	globals.register(top)
+	if err := top.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printHelp(os.Stdout)
+			return exitOK
+		}
+		fmt.Fprintln(os.Stderr, err)
+		printHelp(os.Stderr)
+		return exitUsage
+	}
+	args = top.Args()
+
+	if len(args) == 0 {
+		printHelp(os.Stdout)
+		return exitOK
+	}
+	if args[0] == "help" {
+		if len(args) > 1 {
+			if cmd := findCommand(args[1]); cmd != nil {
+				printUsage(os.Stdout, cmd, newFlagSet(cmd))
+				return exitOK
+			}
+		}
+		printHelp(os.Stdout)
+		return exitOK
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		printHelp(os.Stderr)
+		return exitUsage
+	}
+
+	fs := newFlagSet(cmd)
+	runCmd := cmd.setup(fs)
+	globals.register(fs)
+
+	positional, err := parseInterspersed(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(os.Stdout, cmd, fs)
+		return exitOK
+	}
+	if err == nil && (len(positional) < cmd.minArgs || (cmd.maxArgs >= 0 && len(positional) > cmd.maxArgs)) {
+		err = fmt.Errorf("%s takes %s", cmd.name, argCount(cmd))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		printUsage(os.Stderr, cmd, fs)
+		return exitUsage
+	}
+
+	if err := applyGlobals(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	cfg, err := config.Load()
+	if (errors.Is(err, config.ErrNoProfile) || errors.Is(err, config.ErrForeignAPIBase)) && cmd.name == "profile" {
+		// Managing profiles is how to fix a missing or mismatched one
+		cfg, err = &config.Config{Origins: map[string]config.Origin{}}, nil
+	}
+	if errors.Is(err, config.ErrNoProfile) {
+		failWith(exitUsage, "Config error", err)
+		return exitCode
+	}
+	if err != nil {
+		failWith(exitError, "Config error", err)
+		return exitCode
+	}
+	if globals.apiBase != "" {
+		cfg.APIBaseOverride = strings.TrimRight(globals.apiBase, "/")
+		cfg.Origins["api_base"] = config.Origin{Source: config.SourceFlag, Detail: "--api-base"}
+	}
+	for _, warning := range cfg.Deprecated {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+
+	// The first Ctrl-C cancels the command so it can clean up, a second
+	// one kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	runCmd(ctx, cfg, positional)
+	if ctx.Err() != nil && exitCode == exitOK {
+		exitCode = exitInterrupted
+	}
+	return exitCode
+}
+
+// newFlagSet makes the FlagSet for cmd. Errors and usage are reported by
+// run itself, so the flag package stays silent.
+func newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet("bucket "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseInterspersed parses flags mixed with positional arguments, so both
+// `bucket push --resume f` and `bucket push f --resume` work. Everything
+// after a "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// applyGlobals acts on the global flags once every FlagSet has been parsed
+func applyGlobals() error {
+	format, err := output.ParseFormat(globals.output)
+	if err != nil {
+		return err
+	}
+	if globals.json {
+		format = output.JSON
+	}
+
+	printer = output.New(format)
+	ui = printer.UI
+	status = ui
+	if globals.quiet {
+		status = io.Discard
+	}
+
+	if globals.configPath != "" {
+		config.SetPath(globals.configPath)
+	}
+	if globals.profile != "" {
+		if err := config.ValidProfileName(globals.profile); err != nil {
+			return err
+		}
+		config.SetProfile(globals.profile)
+	}
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		config.SetPassphrase(askPassphrase)
+	}
+	return nil
+}
+
+func argCount(cmd *command) string {
+	switch {
+	case cmd.maxArgs == 0:
+		return "no arguments"
+	case cmd.maxArgs < 0:
+		return fmt.Sprintf("at least %d argument(s)", cmd.minArgs)
+	case cmd.minArgs == cmd.maxArgs:
+		return fmt.Sprintf("exactly %d argument(s)", cmd.minArgs)
+	}
+	return fmt.Sprintf("%d to %d arguments", cmd.minArgs, cmd.maxArgs)
+}
+
+func printUsage(w io.Writer, cmd *command, fs *flag.FlagSet) {
+	if fs.Lookup("config") == nil {
+		cmd.setup(fs)
+		globals.register(fs)
+	}
+
+	line := "bucket " + cmd.name + " [flags]"
+	if cmd.args != "" {
+		line += " " + cmd.args
+	}
+	fmt.Fprintln(w, "Usage:", line)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, cmd.summary)
+
+	var own, global []*flag.Flag
+	fs.VisitAll(func(f *flag.Flag) {
+		if globalNames[f.Name] {
+			global = append(global, f)
+		} else {
+			own = append(own, f)
+		}
+	})
+	if len(own) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Flags:")
+		printFlags(w, own)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	printFlags(w, global)
+}
+
+func printFlags(w io.Writer, flags []*flag.Flag) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range flags {
+		name, usage := flag.UnquoteUsage(f)
+		dash := "--"
+		if len(f.Name) == 1 {
+			dash = "-"
+		}
+		if name != "" {
+			name = " <" + name + ">"
+		}
+		fmt.Fprintf(tw, "  %s%s%s\t%s\n", dash, f.Name, name, usage)
+	}
+	tw.Flush()
+}
+
+// failWith reports a failed command and sets the exit code
+func failWith(code int, msg string, err error) {
+	detail := output.ErrorDetail{ExitCode: code}
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
+		detail.Status = apiErr.Status
+		detail.Code = apiErr.Code
+		detail.RequestID = apiErr.RequestID
+	}
+
+	printer.ErrorWith(msg, err, detail)
+	exitCode = code
+}
+
+// fail reports a failed command, picking the exit code from err
+func fail(msg string, err error) {
+	failWith(exitCodeFor(err), msg, err)
+}
+
+// exitCodeFor sorts an error into one of the documented exit codes
+func exitCodeFor(err error) int {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return exitError
+	case errors.Is(err, api.ErrChecksumMismatch):
+		return exitVerify
+	case errors.Is(err, api.ErrUnauthorized):
+		return exitAuth
+	case errors.Is(err, api.ErrNotFound), errors.Is(err, api.ErrExpired):
+		return exitNotFound
+	case errors.Is(err, api.ErrQuotaExceeded):
+		return exitQuota
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	case errors.As(err, new(*fs.PathError)):
+		return exitError // Local file trouble, its errno would pass for a net.Error
+	case errors.Is(err, api.ErrStalled), errors.As(err, &netErr):
+		return exitNetwork
+	}
+	return exitError
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/output"
+)
+
+//
+// ------------------------------------------------------------
+//  COMPLETION
+// ------------------------------------------------------------
+//
+// The generated scripts are thin: on every <Tab> they run
+//
+//	bucket __complete <n> <word>... [partial]
+//
+// where the first n words after "bucket" are complete and the optional last
+// one is being typed. Candidates come back one per line as "value\tdescription".
+// No output means "complete file names".
+
+const (
+	// How long remote IDs are trusted before asking the server again
+	idCacheTTL = 2 * time.Minute
+
+	// Completion must never hang a shell on a slow network
+	idFetchTimeout = 2 * time.Second
+)
+
+var shells = []string{"bash", "zsh", "fish", "powershell"}
+
+func handleCompletion(shell string) {
+	script, ok := completionScripts[shell]
+	if !ok {
+		failWith(exitUsage, fmt.Sprintf("Unknown shell %q (want %s)", shell, strings.Join(shells, ", ")), nil)
+		return
+	}
+	fmt.Fprint(os.Stdout, script)
+}
+
+// runComplete serves `bucket __complete`. It never fails loudly, a broken
+// completion should just offer nothing.
+func runComplete(args []string) int {
+	if len(args) == 0 {
+		return exitOK
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 || n > len(args)-1 {
+		return exitOK
+	}
+
+	done := args[1 : 1+n]
+	partial := ""
+	if len(args) > 1+n {
+		partial = args[1+n]
+	}
+
+	for _, c := range completions(done, partial) {
+		fmt.Println(c)
+	}
+	return exitOK
+}
+
+// completions lists candidates for partial given the words before it
+func completions(done []string, partial string) []string {
+	var cmd *command
+	var helpCmd bool
+	fs := flag.NewFlagSet("bucket", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var scratch globalOptions
+	scratch.register(fs)
+
+	var pending *flag.Flag // Flag still waiting for its value
+	positional := 0
+	action := "" // First positional word, for commands with actions
+
+	for _, w := range done {
+		if pending != nil {
+			switch pending.Name {
+			case "config":
+				config.SetPath(w)
+			case "profile":
+				config.SetProfile(w)
+			}
+			pending = nil
+			continue
+		}
+		if strings.HasPrefix(w, "-") && w != "-" {
+			name := strings.TrimLeft(w, "-")
+			if strings.Contains(name, "=") {
+				continue
+			}
+			if f := fs.Lookup(name); f != nil && !isBoolFlag(f) {
+				pending = f
+			}
+			continue
+		}
+
+		switch {
+		case cmd == nil && !helpCmd && w == "help":
+			helpCmd = true
+		case cmd == nil && !helpCmd:
+			if cmd = findCommand(w); cmd == nil {
+				return nil
+			}
+			fs = flag.NewFlagSet("bucket "+cmd.name, flag.ContinueOnError)
+			fs.SetOutput(io.Discard)
+			cmd.setup(fs)
+			scratch.register(fs)
+		default:
+			if positional == 0 {
+				action = w
+			}
+			positional++
+		}
+	}
+
+	var candidates []string
+	switch {
+	case pending != nil:
+		switch pending.Name {
+		case "output":
+			for _, f := range []output.Format{output.Text, output.JSON, output.YAML, output.Table} {
+				candidates = append(candidates, string(f))
+			}
+		case "profile":
+			candidates = profileNames()
+		}
+		// Anything else takes a path, a URL or free text
+
+	case strings.HasPrefix(partial, "-"):
+		fs.VisitAll(func(f *flag.Flag) {
+			dash := "--"
+			if len(f.Name) == 1 {
+				dash = "-"
+			}
+			_, usage := flag.UnquoteUsage(f)
+			candidates = append(candidates, dash+f.Name+"\t"+usage)
+		})
+
+	case cmd == nil:
+		for _, c := range commands {
+			candidates = append(candidates, c.name+"\t"+c.summary)
+		}
+		if !helpCmd {
+			candidates = append(candidates, "help\tShow help for a command")
+		}
+
+	case cmd.name == "completion" && positional == 0:
+		candidates = shells
+
+	case cmd.name == "profile" && positional == 0:
+		candidates = profileActions
+
+	case cmd.name == "config" && positional == 0:
+		candidates = configActions
+
+	case cmd.name == "keys" && positional == 0:
+		candidates = keyActions
+
+	case cmd.name == "devices" && positional == 0:
+		candidates = deviceActions
+
+	case cmd.name == "profile" && positional == 1 && (action == "use" || action == "remove"):
+		candidates = profileNames()
+
+	case cmd.completeIDs && positional == 0:
+		cfg, err := config.Load()
+		if err != nil {
+			return nil
+		}
+		for _, f := range cachedIDs(cfg) {
+			candidates = append(candidates, f.TinyCode+"\t"+f.Filename)
+		}
+	}
+
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, partial) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+//
+// Remote ID cache, refreshed by `bucket list` and on demand while completing
+//
+
+type idCache struct {
+	FetchedAt time.Time  `json:"fetched_at"`
+	Files     []cachedID `json:"files"`
+}
+
+type cachedID struct {
+	TinyCode string `json:"tiny_code"`
+	Filename string `json:"filename"`
+}
+
+// idCachePath keeps one cache per profile, they are different accounts
+func idCachePath(cfg *config.Config) string {
+	name := "ids.json"
+	if cfg.Profile != "" && cfg.Profile != config.DefaultProfile {
+		name = "ids-" + cfg.Profile + ".json"
+	}
+	return filepath.Join(config.Dir(), "cache", name)
+}
+
+// cachedIDs returns the account's files for completion, asking the server
+// when the cache is stale. A slow or failing server gets the stale list.
+func cachedIDs(cfg *config.Config) []cachedID {
+	var cache idCache
+	if data, err := os.ReadFile(idCachePath(cfg)); err == nil {
+		_ = json.Unmarshal(data, &cache)
+	}
+	if time.Since(cache.FetchedAt) < idCacheTTL || cfg.APIKey == "" {
+		return cache.Files
+	}
+
+	fetched := make(chan []api.FileInfo, 1)
+	go func() {
+		files, err := newClient(cfg).ListFiles()
+		if err != nil {
+			files = nil
+		}
+		fetched <- files
+	}()
+
+	select {
+	case files := <-fetched:
+		if files == nil {
+			return cache.Files
+		}
+		rememberIDs(cfg, files)
+		return toCachedIDs(files)
+	case <-time.After(idFetchTimeout):
+		return cache.Files
+	}
+}
+
+func toCachedIDs(files []api.FileInfo) []cachedID {
+	ids := make([]cachedID, 0, len(files))
+	for _, f := range files {
+		ids = append(ids, cachedID{TinyCode: f.TinyCode, Filename: f.Filename})
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].TinyCode < ids[j].TinyCode })
+	return ids
+}
+
+// rememberIDs stores a fresh file listing for completion
+func rememberIDs(cfg *config.Config, files []api.FileInfo) {
+	data, err := json.Marshal(idCache{FetchedAt: time.Now(), Files: toCachedIDs(files)})
+	if err != nil {
+		return
+	}
+	path := idCachePath(cfg)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return
+	}
+	_ = os.WriteFile(path, data, 0o600)
+}
+
+// forgetIDs drops the cache after the listing changed under it
+func forgetIDs(cfg *config.Config) {
+	_ = os.Remove(idCachePath(cfg))
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for bucket
+# Load with: source <(bucket completion bash)
+_bucket() {
+    local IFS=$'\n' line
+    local out
+    out=$(bucket __complete "$((COMP_CWORD - 1))" "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)
+    COMPREPLY=()
+    for line in $out; do
+        COMPREPLY+=("${line%%$'\t'*}")
+    done
+}
+complete -o default -F _bucket bucket
+`,
+
+	"zsh": `#compdef bucket
+# zsh completion for bucket
+# Load with: source <(bucket completion zsh)
+_bucket() {
+    local -a lines cands
+    local line
+    lines=("${(@f)$(bucket __complete "$((CURRENT - 2))" "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    for line in $lines; do
+        [[ -z $line ]] && continue
+        if [[ $line == *$'\t'* ]]; then
+            cands+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+        else
+            cands+=("${line//:/\\:}")
+        fi
+    done
+    if (( ${#cands} == 0 )); then
+        _files
+        return
+    fi
+    _describe 'bucket' cands
+}
+compdef _bucket bucket
+`,
+
+	"fish": `# fish completion for bucket
+# Load with: bucket completion fish | source
+function __bucket_complete
+    set -l done (commandline -opc)[2..-1]
+    set -l out (bucket __complete (count $done) $done (commandline -ct) 2>/dev/null)
+    if test (count $out) -eq 0
+        __fish_complete_path (commandline -ct)
+    else
+        printf '%s\n' $out
+    end
+end
+complete -c bucket -f -a '(__bucket_complete)'
+`,
+
+	"powershell": `# PowerShell completion for bucket
+# Load with: bucket completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName bucket -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $words = @($commandAst.CommandElements |
+        Select-Object -Skip 1 |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString() })
+    $done = $words.Count
+    if ($wordToComplete -ne '') { $done-- }
+
+    $out = & bucket __complete $done @words 2>$null
+    if (-not $out) { return }
+    foreach ($line in $out) {
+        $parts = $line -split "` + "`" + `t", 2
+        $desc = if ($parts.Count -gt 1) { $parts[1] } else { $parts[0] }
+        [System.Management.Automation.CompletionResult]::new($parts[0], $parts[0], 'ParameterValue', $desc)
+    }
+}
+`,
+}
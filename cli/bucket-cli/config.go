@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+var configActions = []string{"show"}
+
+//
+// ------------------------------------------------------------
+//  CONFIG
+// ------------------------------------------------------------
+//
+
+func handleConfig(cfg *config.Config, action string, showOrigin bool) {
+	if action != "show" {
+		failWith(exitUsage, fmt.Sprintf("Unknown config action %q (want %s)", action, strings.Join(configActions, ", ")), nil)
+		return
+	}
+
+	workers := cfg.UploadWorkers
+	if workers == 0 {
+		workers = api.DefaultUploadWorkers
+	}
+
+	settings := []setting{
+		{Name: "profile", Value: cfg.Profile},
+		{Name: "api_base", Value: cfg.BaseURL()},
+		{Name: "api_key", Value: maskKey(cfg.APIKey)},
+		{Name: "upload_workers", Value: strconv.Itoa(workers)},
+	}
+	for i := range settings {
+		if showOrigin {
+			origin := cfg.Origins[settings[i].Name]
+			settings[i].Origin = &origin
+		}
+	}
+
+	printer.Result(settings, func() {
+		tw := tabwriter.NewWriter(ui, 0, 0, 2, ' ', 0)
+		for _, s := range settings {
+			if s.Origin != nil {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", s.Name, orDash(s.Value), s.Origin)
+			} else {
+				fmt.Fprintf(tw, "%s\t%s\n", s.Name, orDash(s.Value))
+			}
+		}
+		tw.Flush()
+	})
+}
+
+// setting is one resolved value as `config show` reports it
+type setting struct {
+	Name   string         `json:"name"`
+	Value  string         `json:"value"`
+	Origin *config.Origin `json:"origin,omitempty"`
+}
+
+// maskKey shows just enough of a key to tell two apart
+func maskKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+var deviceActions = []string{"list", "revoke"}
+
+//
+// ------------------------------------------------------------
+//  DEVICES
+// ------------------------------------------------------------
+//
+
+func handleDevices(ctx context.Context, cfg *config.Config, args []string) {
+	action, ref := args[0], ""
+	if len(args) > 1 {
+		ref = args[1]
+	}
+
+	switch action {
+	case "list":
+		if ref != "" {
+			failWith(exitUsage, "devices list takes no device", nil)
+			return
+		}
+	case "revoke":
+		if ref == "" {
+			failWith(exitUsage, "Usage: bucket devices revoke <id|name>", nil)
+			return
+		}
+	default:
+		failWith(exitUsage, fmt.Sprintf("Unknown devices action %q (want %s)", action, strings.Join(deviceActions, ", ")), nil)
+		return
+	}
+	if cfg.APIKey == "" {
+		failWith(exitAuth, "Not logged in. Run: bucket login", nil)
+		return
+	}
+
+	client := newClient(cfg)
+	devices, err := client.ListDevicesContext(ctx)
+	if err != nil {
+		fail("Device list failed", err)
+		return
+	}
+	if devices == nil {
+		devices = []api.Device{}
+	}
+
+	if action == "list" {
+		listDevices(devices)
+		return
+	}
+
+	ids, names := make([]string, len(devices)), make([]string, len(devices))
+	for i, d := range devices {
+		ids[i], names[i] = d.ID, d.Name
+	}
+	i, err := matchRef("device", ref, ids, names)
+	if err != nil {
+		fail("Device revoke failed", err)
+		return
+	}
+	device := devices[i]
+
+	if err := client.RevokeDeviceContext(ctx, device.ID); err != nil {
+		fail("Device revoke failed", err)
+		return
+	}
+	if device.Current {
+		forgetDeadKey(cfg)
+	}
+
+	printer.Result(map[string]interface{}{"revoked": device.ID, "name": device.Name, "current": device.Current}, func() {
+		fmt.Fprintf(ui, "Revoked device %s (%s) and its keys\n", device.Name, device.ID)
+		if device.Current {
+			fmt.Fprintln(ui, "That was this machine, log in again with: bucket login")
+		}
+	})
+}
+
+func listDevices(devices []api.Device) {
+	now := time.Now()
+	printer.Result(devices, func() {
+		tw := tabwriter.NewWriter(ui, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  ID\tNAME\tADDED\tLAST SEEN")
+		for _, d := range devices {
+			fmt.Fprintf(tw, "%s %s\t%s\t%s\t%s\n", currentMark(d.Current), d.ID, d.Name,
+				orDash(d.CreatedAt), describeAgo(d.LastSeenAt, now))
+		}
+		tw.Flush()
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+//
+// ------------------------------------------------------------
+//  EXTEND
+// ------------------------------------------------------------
+//
+
+func handleExtend(ctx context.Context, cfg *config.Config, tiny string, by time.Duration, until time.Time) {
+	if cfg.APIKey == "" {
+		failWith(exitAuth, "Not logged in. Run: bucket login", nil)
+		return
+	}
+
+	client := newClient(cfg)
+
+	tiny = api.ExtractTinyCode(tiny)
+	extend := api.ExtendRequest{Tiny: tiny}
+	if by > 0 {
+		extend.ExtendBy = int64(by / time.Second)
+	} else {
+		extend.ExpiresAt = formatExpiry(until)
+	}
+
+	resp, err := client.ExtendFileContext(ctx, extend)
+	if err != nil {
+		fail("Extend failed", err)
+		return
+	}
+
+	printer.Result(map[string]string{"id": tiny, "expires_at": resp.ExpiresAt}, func() {
+		fmt.Fprintln(ui, "Extended:", tiny)
+		fmt.Fprintln(ui, "Expires: ", resp.ExpiresAt)
+	})
+}
+
+// Days and weeks in front of an optional Go duration, e.g. "1w2d" or "3d12h"
+var longDuration = regexp.MustCompile(`^(?:(\d+)w)?(?:(\d+)d)?(.*)$`)
+
+// maxDays is the longest whole number of days a time.Duration holds
+const maxDays = int64(math.MaxInt64 / (24 * time.Hour))
+
+// parseDuration is time.ParseDuration that also knows d (days) and w (weeks)
+func parseDuration(s string) (time.Duration, error) {
+	m := longDuration.FindStringSubmatch(s)
+	if m == nil || s == "" {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	var days int64
+	for i, perDay := range []int64{7, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(m[i+1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", s, err)
+		}
+		if n > (maxDays-days)/perDay {
+			return 0, fmt.Errorf("invalid duration %q: longer than %d days", s, maxDays)
+		}
+		days += n * perDay
+	}
+
+	d := time.Duration(days) * 24 * time.Hour
+	if m[3] != "" {
+		rest, err := time.ParseDuration(m[3])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		if rest > 0 && d > math.MaxInt64-rest {
+			return 0, fmt.Errorf("invalid duration %q: longer than %d days", s, maxDays)
+		}
+		d += rest
+	}
+	return d, nil
+}
+
+// parseExpiry reads a lifetime from now ("2h", "7d") or a point in time
+// ("2026-12-01", "2026-12-01 18:00", RFC 3339). Dates without a zone are
+// local time. The result must lie in the future.
+func parseExpiry(s string, now time.Time) (time.Time, error) {
+	var t time.Time
+	if d, err := parseDuration(s); err == nil {
+		t = now.Add(d)
+	} else if t, err = time.Parse(time.RFC3339, s); err != nil {
+		for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+			if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expiry %q: want a duration like 2h or 7d, or a date like 2026-12-01", s)
+		}
+	}
+
+	if !t.After(now) {
+		return time.Time{}, fmt.Errorf("expiry %q is not in the future", s)
+	}
+	return t, nil
+}
+
+// formatExpiry renders t for the API, empty for "server default"
+func formatExpiry(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
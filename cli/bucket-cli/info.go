@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+//
+// ------------------------------------------------------------
+//  INFO
+// ------------------------------------------------------------
+//
+
+func handleInfo(ctx context.Context, cfg *config.Config, tiny string) {
+	if cfg.APIKey == "" {
+		failWith(exitAuth, "Not logged in. Run: bucket login", nil)
+		return
+	}
+
+	client := newClient(cfg)
+
+	info, err := client.GetFileContext(ctx, api.ExtractTinyCode(tiny))
+	if err != nil {
+		fail("Info failed", err)
+		return
+	}
+
+	printer.Result(info, func() {
+		tw := tabwriter.NewWriter(ui, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID:\t%s\n", info.TinyCode)
+		fmt.Fprintf(tw, "Filename:\t%s\n", info.Filename)
+		fmt.Fprintf(tw, "Size:\t%s (%d bytes)\n", humanSize(info.SizeBytes), info.SizeBytes)
+		fmt.Fprintf(tw, "Uploaded:\t%s\n", orDash(info.UploadedAt))
+		fmt.Fprintf(tw, "Expires:\t%s\n", describeExpiry(info.ExpiresAt, time.Now()))
+		fmt.Fprintf(tw, "SHA256:\t%s\n", orDash(info.SHA256))
+		fmt.Fprintf(tw, "Encrypted:\t%s\n", yesNo(info.Encrypted))
+		fmt.Fprintf(tw, "Downloads:\t%d so far, remaining: %s\n", info.DownloadCount, downloadsLeft(*info))
+		fmt.Fprintf(tw, "Last access:\t%s\n", orDefault(info.LastAccessedAt, "never"))
+		if info.SecretRotatedAt != "" {
+			fmt.Fprintf(tw, "Secret:\trotated %s\n", info.SecretRotatedAt)
+		} else {
+			fmt.Fprintf(tw, "Secret:\toriginal\n")
+		}
+		tw.Flush()
+	})
+}
+
+// describeExpiry adds a countdown to an RFC 3339 expiry
+func describeExpiry(expiresAt string, now time.Time) string {
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return orDash(expiresAt)
+	}
+	left := t.Sub(now)
+	if left <= 0 {
+		return expiresAt + " (expired)"
+	}
+	return fmt.Sprintf("%s (in %s)", expiresAt, humanDuration(left))
+}
+
+// humanDuration renders d coarsely, e.g. "3d 4h" or "12m"
+func humanDuration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	switch {
+	case days > 0:
+		return strconv.Itoa(days) + "d " + strconv.Itoa(hours) + "h"
+	case hours > 0:
+		return strconv.Itoa(hours) + "h " + strconv.Itoa(minutes) + "m"
+	case minutes > 0:
+		return strconv.Itoa(minutes) + "m"
+	}
+	return "under a minute"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func orDash(s string) string {
+	return orDefault(s, "-")
+}
+
+func orDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
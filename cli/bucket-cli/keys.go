@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+var keyActions = []string{"create", "list", "revoke", "rename"}
+
+// keyOptions are the flags of `bucket keys`
+type keyOptions struct {
+	name   string
+	scopes []string
+}
+
+//
+// ------------------------------------------------------------
+//  API KEYS
+// ------------------------------------------------------------
+//
+
+func handleKeys(ctx context.Context, cfg *config.Config, args []string, opts keyOptions) {
+	action, rest := args[0], args[1:]
+
+	want := map[string]int{"create": 0, "list": 0, "revoke": 1, "rename": 2}
+	n, ok := want[action]
+	if !ok {
+		failWith(exitUsage, fmt.Sprintf("Unknown keys action %q (want %s)", action, strings.Join(keyActions, ", ")), nil)
+		return
+	}
+	if len(rest) != n {
+		usage := map[string]string{"create": "keys create --name <name>", "list": "keys list",
+			"revoke": "keys revoke <id|name>", "rename": "keys rename <id|name> <new name>"}
+		failWith(exitUsage, "Usage: bucket "+usage[action], nil)
+		return
+	}
+	if cfg.APIKey == "" {
+		failWith(exitAuth, "Not logged in. Run: bucket login", nil)
+		return
+	}
+
+	client := newClient(cfg)
+	switch action {
+	case "create":
+		createKey(ctx, client, opts)
+	case "list":
+		listKeys(ctx, client)
+	case "revoke":
+		revokeKey(ctx, cfg, client, rest[0])
+	case "rename":
+		renameKey(ctx, client, rest[0], rest[1])
+	}
+}
+
+func createKey(ctx context.Context, client *api.Client, opts keyOptions) {
+	if opts.name == "" {
+		failWith(exitUsage, "keys create needs --name, e.g. --name ci-release", nil)
+		return
+	}
+
+	key, err := client.CreateKeyContext(ctx, api.CreateKeyRequest{Name: opts.name, Scopes: opts.scopes})
+	if err != nil {
+		fail("Key creation failed", err)
+		return
+	}
+
+	printer.Result(key, func() {
+		fmt.Fprintf(ui, "Created key %s (%s), scopes: %s\n\n", key.Name, key.ID, describeScopes(key.Scopes))
+		fmt.Fprintln(ui, "  ", key.Key)
+		fmt.Fprintln(ui, "\nIt is shown only this once. Store it as a CI secret and log in with:")
+		fmt.Fprintln(ui, "  bucket login --api-key-stdin   or   BUCKET_API_KEY=... bucket <command>")
+	})
+}
+
+func listKeys(ctx context.Context, client *api.Client) {
+	keys, err := client.ListKeysContext(ctx)
+	if err != nil {
+		fail("Key list failed", err)
+		return
+	}
+	if keys == nil {
+		keys = []api.APIKey{}
+	}
+
+	now := time.Now()
+	printer.Result(keys, func() {
+		tw := tabwriter.NewWriter(ui, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  ID\tNAME\tDEVICE\tSCOPES\tCREATED\tLAST USED")
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s %s\t%s\t%s\t%s\t%s\t%s\n", currentMark(k.Current), k.ID, k.Name,
+				orDash(k.DeviceName), describeScopes(k.Scopes), orDash(k.CreatedAt), describeAgo(k.LastUsedAt, now))
+		}
+		tw.Flush()
+	})
+}
+
+func revokeKey(ctx context.Context, cfg *config.Config, client *api.Client, ref string) {
+	keys, err := client.ListKeysContext(ctx)
+	if err != nil {
+		fail("Key list failed", err)
+		return
+	}
+	ids, names := make([]string, len(keys)), make([]string, len(keys))
+	for i, k := range keys {
+		ids[i], names[i] = k.ID, k.Name
+	}
+	i, err := matchRef("key", ref, ids, names)
+	if err != nil {
+		fail("Key revoke failed", err)
+		return
+	}
+	key := keys[i]
+
+	if err := client.RevokeKeyContext(ctx, key.ID); err != nil {
+		fail("Key revoke failed", err)
+		return
+	}
+	if key.Current {
+		forgetDeadKey(cfg)
+	}
+
+	printer.Result(map[string]interface{}{"revoked": key.ID, "name": key.Name, "current": key.Current}, func() {
+		fmt.Fprintf(ui, "Revoked key %s (%s)\n", key.Name, key.ID)
+		if key.Current {
+			fmt.Fprintln(ui, "That was this machine's key, log in again with: bucket login")
+		}
+	})
+}
+
+func renameKey(ctx context.Context, client *api.Client, ref, name string) {
+	keys, err := client.ListKeysContext(ctx)
+	if err != nil {
+		fail("Key list failed", err)
+		return
+	}
+	ids, names := make([]string, len(keys)), make([]string, len(keys))
+	for i, k := range keys {
+		ids[i], names[i] = k.ID, k.Name
+	}
+	i, err := matchRef("key", ref, ids, names)
+	if err != nil {
+		fail("Key rename failed", err)
+		return
+	}
+
+	key, err := client.RenameKeyContext(ctx, keys[i].ID, name)
+	if err != nil {
+		fail("Key rename failed", err)
+		return
+	}
+	printer.Result(key, func() {
+		fmt.Fprintf(ui, "Renamed key %s to %s\n", keys[i].Name, key.Name)
+	})
+}
+
+// matchRef finds what ref names among items with the given IDs and names.
+// An exact ID wins; a name must be unique to be used.
+func matchRef(kind, ref string, ids, names []string) (int, error) {
+	for i, id := range ids {
+		if id == ref {
+			return i, nil
+		}
+	}
+
+	found := -1
+	for i, name := range names {
+		if name != ref {
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("several %ss are named %q, use the ID instead", kind, ref)
+		}
+		found = i
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("no %s with ID or name %q: %w", kind, ref, api.ErrNotFound)
+	}
+	return found, nil
+}
+
+// forgetDeadKey drops the saved key once the server no longer accepts it
+func forgetDeadKey(cfg *config.Config) {
+	cfg.APIKey = ""
+	_ = config.Save(cfg)
+}
+
+func describeScopes(scopes []string) string {
+	if len(scopes) == 0 {
+		return "all"
+	}
+	return strings.Join(scopes, ",")
+}
+
+// describeAgo renders an RFC 3339 time as how long ago it was
+func describeAgo(at string, now time.Time) string {
+	if at == "" {
+		return "never"
+	}
+	t, err := time.Parse(time.RFC3339, at)
+	if err != nil {
+		return at
+	}
+	return humanDuration(now.Sub(t)) + " ago"
+}
+
+func currentMark(current bool) string {
+	if current {
+		return "*"
+	}
+	return " "
+}
@@ -2,75 +2,53 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
-	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
-	"syscall"
+	"text/tabwriter"
 	"time"
-	
+
 	"golang.org/x/term"
 
 	"github.com/google/uuid"
 
 	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/archive"
 	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/encrypt"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/output"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/progress"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/resume"
 )
 
-func main() {
-	cfg, err := config.Load()
-	if err != nil {
-		fmt.Println("Config error:", err)
-		return
-	}
-
-	if len(os.Args) < 2 {
-		printHelp()
-		return
-	}
+// Human output goes to ui; in --json/--output modes that is stderr so stdout
+// only carries the structured result. status is ui, or nothing with --quiet.
+var (
+	printer           = output.New(output.Text)
+	ui      io.Writer = os.Stdout
+	status  io.Writer = os.Stdout
+)
 
-	switch os.Args[1] {
+const (
+	// How long cancelled transfers get to unwind after Ctrl-C
+	transferGrace = 5 * time.Second
 
-	case "account":
-		handleAccount(cfg)
-		return
-	case "del":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: bucket del <id>")
-			return
-		}
-		handleDelete(cfg, os.Args[2])
-		return
-	case "login":
-		handleLogin(cfg)
-		return
-	case "logout": 
-		handleLogout(cfg)
-		return 
-	case "push":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: bucket push <file>")
-			return
-		}
-		handlePush(cfg, os.Args[2])
-		return
-	case "pull":
-		if len(os.Args) < 3 {
-			fmt.Println("Usage: bucket pull <bURL>")
-			return
-		}
-		handlePull(cfg, os.Args[2])
-		return
-	case "list":
-		handleList(cfg)
-		return
+	// How long removing an interrupted upload may take
+	cleanupTimeout = 15 * time.Second
+)
 
-	default:
-		printHelp()
-	}
+func main() {
+	os.Exit(run(os.Args[1:]))
 }
 
 //
@@ -78,36 +56,47 @@ func main() {
 //  LOGIN/LOGOUT
 // ------------------------------------------------------------
 //
-func handleLogout(cfg *config.Config) {
+
+func handleLogout(ctx context.Context, cfg *config.Config) {
 	if cfg.APIKey == "" {
-		fmt.Println("You are not currently logged in.")
+		failWith(exitAuth, "You are not currently logged in.", nil)
 		return
 	}
 
-	client := api.New(cfg)
+	client := newClient(cfg)
 
 	// Best-effort server-side logout
-	_ = client.Logout()
+	_ = client.LogoutContext(ctx)
 
-	deleteJson()
+	clearLogin(cfg)
 }
 
+// loginOptions pick a non-interactive login with an existing API key
+type loginOptions struct {
+	keyStdin bool
+	keyFile  string
+}
+
+func handleLogin(ctx context.Context, cfg *config.Config, opts loginOptions) {
+	if opts.keyStdin || opts.keyFile != "" {
+		loginWithKey(ctx, cfg, opts)
+		return
+	}
 
-func handleLogin(cfg *config.Config) {
 	if cfg.APIKey != "" {
-		fmt.Println("Already logged in with API key:", cfg.APIKey)
-		fmt.Print("Log out? (y/n): ")
+		fmt.Fprintln(ui, "Already logged in, API key kept in", config.KeyLocation(cfg))
+		fmt.Fprint(ui, "Log out? (y/n): ")
 		reader := bufio.NewReader(os.Stdin)
 		answer, _ := reader.ReadString('\n')
 
 		if strings.TrimSpace(strings.ToLower(answer)) == "y" {
-			deleteJson()
+			clearLogin(cfg)
 		}
 		return
 	}
 
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("Email: ")
+	fmt.Fprint(ui, "Email: ")
 	email, _ := reader.ReadString('\n')
 	email = strings.TrimSpace(email)
 
@@ -116,53 +105,111 @@ func handleLogin(cfg *config.Config) {
 	ensureDeviceIdentity(cfg, email)
 
 	// client now has device_id + name
-	client := api.New(cfg)
+	client := newClient(cfg)
 
 	// --- 2FA FLOW ---
 	var apiKey string
 	var err error
 	var otpCode string
 
-	apiKey, err = client.Login(email, password, otpCode)
+	apiKey, err = client.LoginContext(ctx, email, password, otpCode)
 
 	if err != nil {
 		if _, ok := err.(*api.TwoFARequiredError); ok {
-			fmt.Printf("2FA code has been sent to %s", email)
+			fmt.Fprintf(ui, "2FA code has been sent to %s", email)
 			otpCode = readSecret("\n2FA code: ")
-			fmt.Println("Retrying with 2FA code...")
-			apiKey, err = client.Login(email, password, otpCode)
+			fmt.Fprintln(status, "Retrying with 2FA code...")
+			apiKey, err = client.LoginContext(ctx, email, password, otpCode)
 		}
 	}
 
 	if err != nil {
-		fmt.Println("Account error:", err)
+		fail("Account error", err)
 		return
 	}
 
 	cfg.APIKey = apiKey
-	_ = config.Save(cfg)
+	if err := config.Save(cfg); err != nil {
+		fail("Config error", err)
+		return
+	}
 
-	fmt.Println("Account ready.")
-	fmt.Println("API Key:", apiKey)
+	// The key itself is never printed, only where it went
+	printer.Result(map[string]interface{}{"logged_in": true, "email": email, "key_store": config.KeyLocation(cfg)}, func() {
+		fmt.Fprintln(ui, "Account ready.")
+		fmt.Fprintln(ui, "API key saved to", config.KeyLocation(cfg))
+	})
 }
 
+// loginWithKey saves a key issued elsewhere, e.g. by `bucket keys create`,
+// once the server has accepted it. Nothing is prompted, so it works on
+// headless runners.
+func loginWithKey(ctx context.Context, cfg *config.Config, opts loginOptions) {
+	var data []byte
+	var err error
+	source := "stdin"
+	if opts.keyStdin {
+		data, err = io.ReadAll(io.LimitReader(os.Stdin, 64*1024))
+	} else {
+		source = opts.keyFile
+		data, err = os.ReadFile(opts.keyFile)
+	}
+	if err != nil {
+		fail("Cannot read API key", err)
+		return
+	}
+	apiKey := strings.TrimSpace(string(data))
+	if apiKey == "" || strings.ContainsAny(apiKey, " \t\r\n") {
+		failWith(exitUsage, "Expected a single API key on "+source, nil)
+		return
+	}
+
+	// Check the key before replacing a working one with it
+	probe := *cfg
+	probe.APIKey = apiKey
+	info, err := newClient(&probe).FetchAccountInfoContext(ctx)
+	if err != nil {
+		fail("API key rejected", err)
+		return
+	}
+
+	cfg.APIKey = apiKey
+	cfg.Tier = info.Tier
+	cfg.UsedBytes = info.UsedBytes
+	cfg.Quota = info.Quota
+	delete(cfg.Origins, "api_key") // Save even when BUCKET_API_KEY is set for this run
+	if err := config.Save(cfg); err != nil {
+		fail("Config error", err)
+		return
+	}
+
+	printer.Result(map[string]interface{}{"logged_in": true, "tier": info.Tier, "key_store": config.KeyLocation(cfg)}, func() {
+		fmt.Fprintf(ui, "Logged in (%s tier).\n", orDefault(info.Tier, "unknown"))
+		fmt.Fprintln(ui, "API key saved to", config.KeyLocation(cfg))
+	})
+}
 
 //
 // ------------------------------------------------------------
-//  ACCOUNT 
+//  ACCOUNT
 // ------------------------------------------------------------
 //
-func handleAccount(cfg *config.Config) {
+
+func handleAccount(ctx context.Context, cfg *config.Config) {
 	if cfg.APIKey == "" {
-		fmt.Println("Not logged in. Run: bucket login")
+		failWith(exitAuth, "Not logged in. Run: bucket login", nil)
 		return
 	}
 
-	client := api.New(cfg)
+	client := newClient(cfg)
 
-	info, err := client.FetchAccountInfo()
+	info, err := client.FetchAccountInfoContext(ctx)
 	if err != nil {
-		fmt.Println("Error: Fetch failed:", err)
+		if printer.Structured() {
+			fail("Fetch failed", err)
+			return
+		}
+		fmt.Fprintln(ui, "Error: Fetch failed:", err)
 	} else {
 		cfg.Tier = info.Tier
 		cfg.UsedBytes = info.UsedBytes
@@ -170,17 +217,19 @@ func handleAccount(cfg *config.Config) {
 		_ = config.Save(cfg)
 	}
 
-	fmt.Println("Account Info")
-	fmt.Println("------------")
-	fmt.Println("Subscription:", cfg.Tier)
-	fmt.Println("Used:", humanSize(cfg.UsedBytes))
-	fmt.Println("Quota:", humanSize(cfg.Quota))
-	fmt.Println()
-
-	if cfg.Tier != "premium" && cfg.Tier != "bkt_dev" {
-		fmt.Println("To increase storage limits, visit:")
-		fmt.Println("  https://bucketlabs.org/auth")
-	}
+	printer.Result(info, func() {
+		fmt.Fprintln(ui, "Account Info")
+		fmt.Fprintln(ui, "------------")
+		fmt.Fprintln(ui, "Subscription:", cfg.Tier)
+		fmt.Fprintln(ui, "Used:", humanSize(cfg.UsedBytes))
+		fmt.Fprintln(ui, "Quota:", humanSize(cfg.Quota))
+		fmt.Fprintln(ui)
+
+		if cfg.Tier != "premium" && cfg.Tier != "bkt_dev" {
+			fmt.Fprintln(ui, "To increase storage limits, visit:")
+			fmt.Fprintln(ui, "  https://bucketlabs.org/auth")
+		}
+	})
 }
 
 //
@@ -188,83 +237,498 @@ func handleAccount(cfg *config.Config) {
 //  PUSH
 // ------------------------------------------------------------
 //
-func handlePush(cfg *config.Config, filepath string) {
-    if cfg.APIKey == "" {
-        fmt.Println("Not logged in. Run: bucket account")
-        return
-    }
-
-    stat, err := os.Stat(filepath)
-    if err != nil {
-        fmt.Println("File error:", err)
-        return
-    }
-
-    client := api.New(cfg)
-
-    uploadInit, err := client.RequestUpload(stat.Name(), stat.Size())
-    if err != nil {
-        fmt.Println("Upload failed:", err)
-        return
-    }
-
-    // Setup interrupt handler for cleanup
-    sigChan := make(chan os.Signal, 1)
-    signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-    
-    uploadDone := make(chan error, 1)
-    spinnerDone := make(chan bool)
-    fileID := uploadInit.FileID
-
-    // Cleanup function
-    cleanup := func() {
-        fmt.Println("\n\n⚠️  Upload interrupted. Cleaning up...")
-        if err := client.CleanupFailedUpload(fileID); err != nil {
-            fmt.Println("Warning: Failed to cleanup incomplete upload:", err)
-        } else {
-            fmt.Println("✓ Incomplete upload removed")
-        }
-    }
-
-    // Start spinner
-    go showSpinner(spinnerDone, "Uploading...")
-
-    // Upload in goroutine
-    go func() {
-        uploadDone <- client.UploadFile(uploadInit.UploadURL, filepath)
-    }()
-
-    // Wait for upload or interrupt
-    select {
-    case err := <-uploadDone:
-        spinnerDone <- true // Stop spinner
-        signal.Stop(sigChan)
-        if err != nil {
-            fmt.Println("Upload failed:", err)
-            cleanup()
-            return
-        }
-    case <-sigChan:
-        spinnerDone <- true // Stop spinner
-        cleanup()
-        os.Exit(130) // Standard exit code for SIGINT
-    }
-
-    // VERIFY UPLOAD SUCCESS
-    fmt.Print("⏳ Verifying upload...")
-    err = client.VerifyUpload(uploadInit.FileID)
-    if err != nil {
-        fmt.Println("\n❌ Upload verification failed:", err)
-        fmt.Println("The file was not pushed. Please try again")
-        cleanup()
-        return
-    }
-
-    fmt.Println("\n\n\t   ✓ Upload complete!\n")
-    fmt.Println("    bID: ", uploadInit.TinyCode)
-    fmt.Println("   bURL: ", "api.bucketlabs.org"+"/d/"+uploadInit.TinyCode)
-    fmt.Println(" Secret: ", uploadInit.Secret)
-    fmt.Println("Expires: ", uploadInit.ExpiresAt)
+
+type pushOptions struct {
+	resume       bool      // Journal progress so an interrupted push can continue
+	encrypt      bool      // Encrypt client-side before anything leaves the machine
+	recipients   []string  // Encrypt for these public keys instead of the secret
+	compress     bool      // zstd-compress archives of directories or many files
+	name         string    // Filename to store under, for stdin and archives
+	expires      time.Time // Zero for the server's default lifetime
+	expiresFlag  string    // --expires as given, to tell a resume apart
+	maxDownloads int       // 0 for unlimited
+	burn         bool      // Delete after the first successful download
+}
+
+func handlePush(ctx context.Context, cfg *config.Config, localPath string, opts pushOptions) {
+	if cfg.APIKey == "" {
+		failWith(exitAuth, "Not logged in. Run: bucket account", nil)
+		return
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		fail("File error", err)
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		fail("File error", err)
+		return
+	}
+
+	recipients, err := parseRecipients(opts.recipients)
+	if err != nil {
+		fail("Recipient error", err)
+		return
+	}
+	encrypted := opts.encrypt || len(recipients) > 0
+
+	client := newClient(cfg)
+
+	size := stat.Size()
+	if encrypted {
+		size = encrypt.EncryptedSize(size, len(recipients))
+	}
+	name := stat.Name()
+	if opts.name != "" {
+		name = opts.name
+	}
+	request := api.UploadRequest{
+		Filename:  name,
+		SizeBytes: size,
+		Multipart: size >= api.MultipartThreshold,
+		Encrypted: encrypted,
+		ExpiresAt: formatExpiry(opts.expires),
+
+		Recipients: len(recipients) > 0,
+
+		MaxDownloads: opts.maxDownloads,
+		Burn:         opts.burn,
+	}
+
+	var key []byte
+	if encrypted {
+		if key, err = encrypt.NewKey(); err != nil {
+			fail("Encryption error", err)
+			return
+		}
+	}
+
+	var journal *resume.Journal
+	var uploadInit *api.UploadInitResponse
+	if opts.resume {
+		journalOpts := resume.Options{
+			Name:         name,
+			Expires:      opts.expiresFlag,
+			MaxDownloads: opts.maxDownloads,
+			Burn:         opts.burn,
+			Recipients:   opts.recipients,
+		}
+		journal, uploadInit, err = resumeOrStartUpload(ctx, client, localPath, request, journalOpts, key)
+		if err == nil && journal != nil && journal.Encrypted() {
+			var encoded string
+			if encoded, err = journal.LoadKey(); err == nil {
+				key, err = encrypt.DecodeKey(encoded)
+			}
+		}
+	} else {
+		uploadInit, err = client.RequestUploadWithContext(ctx, request)
+	}
+	if err != nil {
+		fail("Upload failed", err)
+		return
+	}
+
+	var src io.ReaderAt = f
+	secret := uploadInit.Secret
+	if key != nil {
+		var enc *encrypt.ReaderAt
+		if len(recipients) > 0 {
+			// Only the recipients' identities can open it, the secret stays as-is
+			enc, err = encrypt.NewRecipientReaderAt(f, stat.Size(), key, recipients)
+		} else {
+			enc, err = encrypt.NewReaderAt(f, stat.Size(), key)
+			secret = encrypt.JoinSecret(uploadInit.Secret, key)
+		}
+		if err != nil {
+			fail("Encryption error", err)
+			return
+		}
+		src = enc
+	}
+
+	// Checksum the bytes being stored as they are sent
+	hasher := api.NewHasher(src, size)
+	defer hasher.Close()
+	src = hasher
+	if journal != nil && uploadInit.IsMultipart() {
+		for _, p := range journal.Completed {
+			hasher.Skip(uploadInit.PartRange(p.Number, size))
+		}
+	}
+
+	uploadDone := make(chan error, 1)
+	fileID := uploadInit.FileID
+
+	// Removes an upload that will not be finished
+	discard := func() {
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if err := client.CleanupFailedUploadContext(cleanupCtx, fileID); err != nil {
+			fmt.Fprintln(ui, "Warning: Failed to cleanup incomplete upload:", err)
+		} else {
+			fmt.Fprintln(status, "✓ Incomplete upload removed")
+		}
+		if journal != nil {
+			_ = journal.Remove()
+		}
+	}
+
+	cleanup := func() {
+		fmt.Fprintln(ui, "\n\n⚠️  Upload interrupted. Cleaning up...")
+		discard()
+	}
+
+	// With --resume an interrupt or failure keeps what was already sent
+	abandon := func() {
+		if journal == nil {
+			cleanup()
+			return
+		}
+		fmt.Fprintln(ui, "\n\n⚠️  Upload interrupted. Progress saved.")
+		fmt.Fprintf(ui, "Run: bucket push --resume %s\n", localPath)
+	}
+
+	// Start progress
+	bar := progress.New("Uploading", size)
+	if journal != nil && uploadInit.IsMultipart() {
+		bar.Add(uploadInit.SentBytes(size, journal.Completed))
+	}
+	client.SetProgress(bar)
+	startProgress(bar)
+
+	// Upload in goroutine
+	go func() {
+		if !uploadInit.IsMultipart() {
+			uploadDone <- client.UploadReaderContext(ctx, uploadInit.UploadURL, io.NewSectionReader(src, 0, size), size)
+			return
+		}
+
+		var done []api.CompletedPart
+		var onPart func(api.CompletedPart)
+		if journal != nil {
+			done = journal.Completed
+			onPart = func(p api.CompletedPart) { _ = journal.Record(p) }
+		}
+
+		parts, err := client.ResumeMultipartContext(ctx, uploadInit, src, size, cfg.UploadWorkers, done, onPart)
+		if err == nil {
+			err = client.CompleteUploadContext(ctx, uploadInit.FileID, uploadInit.UploadID, parts)
+		}
+		uploadDone <- err
+	}()
+
+	// Wait for upload or interrupt
+	err = waitTransfer(ctx, uploadDone)
+	bar.Stop()
+	if ctx.Err() != nil {
+		abandon()
+		exitCode = exitInterrupted
+		return
+	}
+	if err != nil {
+		fail("Upload failed", err)
+		abandon()
+		return
+	}
+
+	// VERIFY UPLOAD SUCCESS
+	fmt.Fprint(status, "⏳ Verifying upload...")
+	sum, err := hasher.Sum()
+	if err != nil {
+		fmt.Fprintln(status)
+		fail("Checksum error", err)
+		discard()
+		return
+	}
+	if err := client.VerifyUploadContext(ctx, uploadInit.FileID, sum); err != nil {
+		fmt.Fprintln(status)
+		failWith(exitVerify, "❌ Upload verification failed", err)
+		fmt.Fprintln(ui, "The file was not pushed. Please try again")
+		discard()
+		return
+	}
+
+	if journal != nil {
+		_ = journal.Remove()
+	}
+
+	printUploadResult(cfg, uploadInit, secret, sum, opts)
+}
+
+// pushResult is what --json and --output report for a finished push
+type pushResult struct {
+	ID        string `json:"id"`
+	URL       string `json:"url"`
+	Secret    string `json:"secret"`
+	ExpiresAt string `json:"expires_at"`
+	SHA256    string `json:"sha256,omitempty"`
+	FileID    string `json:"file_id,omitempty"`
+
+	MaxDownloads int  `json:"max_downloads,omitempty"`
+	Burn         bool `json:"burn_after_reading,omitempty"`
+	KeyRequired  bool `json:"key_required,omitempty"` // Secret lacks the #key part, the original one must be appended
+}
+
+func printUploadResult(cfg *config.Config, uploadInit *api.UploadInitResponse, secret, sha256 string, opts pushOptions) {
+	result := pushResult{
+		ID:        uploadInit.TinyCode,
+		URL:       shareURL(cfg, uploadInit.TinyCode),
+		Secret:    secret,
+		ExpiresAt: uploadInit.ExpiresAt,
+		SHA256:    sha256,
+		FileID:    uploadInit.FileID,
+
+		MaxDownloads: opts.maxDownloads,
+		Burn:         opts.burn,
+	}
+
+	forgetIDs(cfg)
+	printShare("✓ Upload complete!", result)
+}
+
+// printShare renders the bID/bURL/Secret block someone needs to pull a file
+func printShare(title string, result pushResult) {
+	printer.Result(result, func() {
+		fmt.Fprintf(ui, "\n\n\t   %s\n\n", title)
+		fmt.Fprintln(ui, "    bID: ", result.ID)
+		fmt.Fprintln(ui, "   bURL: ", result.URL)
+		fmt.Fprintln(ui, " Secret: ", result.Secret)
+		fmt.Fprintln(ui, "Expires: ", result.ExpiresAt)
+		if result.SHA256 != "" {
+			fmt.Fprintln(ui, " SHA256: ", result.SHA256)
+		}
+		if result.Burn {
+			fmt.Fprintln(ui, "  Limit:  burn after reading (deleted after the first download)")
+		} else if result.MaxDownloads > 0 {
+			fmt.Fprintf(ui, "  Limit:  %d downloads\n", result.MaxDownloads)
+		}
+	})
+}
+
+// shareURL is the bURL for a file on the server cfg talks to
+func shareURL(cfg *config.Config, tiny string) string {
+	return strings.TrimPrefix(cfg.BaseURL(), "https://") + "/d/" + tiny
+}
+
+// handlePushArchive pushes directories or several files as one tar stream,
+// built on the fly so nothing is staged on disk
+func handlePushArchive(ctx context.Context, cfg *config.Config, paths []string, opts pushOptions) {
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err != nil {
+			fail("File error", err)
+			return
+		}
+	}
+	if err := archive.CheckRoots(paths); err != nil {
+		fail("Archive error", err)
+		return
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.Write(pw, paths, opts.compress))
+	}()
+
+	name := opts.name
+	if name == "" {
+		name = archive.Name(paths, opts.compress)
+	}
+	pushStream(ctx, cfg, name, pr, opts)
+}
+
+// pushStream uploads a body of unknown length as a streaming multipart
+// upload. Encryption and hashing happen inline as the bytes go out.
+func pushStream(ctx context.Context, cfg *config.Config, name string, body io.Reader, opts pushOptions) {
+	if cfg.APIKey == "" {
+		failWith(exitAuth, "Not logged in. Run: bucket account", nil)
+		return
+	}
+	if opts.resume {
+		fmt.Fprintln(ui, "Note: --resume only applies to single files, ignoring it.")
+	}
+
+	recipients, err := parseRecipients(opts.recipients)
+	if err != nil {
+		fail("Recipient error", err)
+		return
+	}
+	encrypted := opts.encrypt || len(recipients) > 0
+
+	client := newClient(cfg)
+
+	uploadInit, err := client.RequestUploadWithContext(ctx, api.UploadRequest{
+		Filename:  name,
+		Multipart: true,
+		Streaming: true,
+		Encrypted: encrypted,
+		ExpiresAt: formatExpiry(opts.expires),
+
+		Recipients: len(recipients) > 0,
+
+		MaxDownloads: opts.maxDownloads,
+		Burn:         opts.burn,
+	})
+	if err != nil {
+		fail("Upload failed", err)
+		return
+	}
+
+	secret := uploadInit.Secret
+	if encrypted {
+		key, err := encrypt.NewKey()
+		if err == nil {
+			body, err = encrypt.NewReader(body, key, recipients)
+		}
+		if err != nil {
+			fail("Encryption error", err)
+			_ = client.CleanupFailedUploadContext(ctx, uploadInit.FileID)
+			return
+		}
+		if len(recipients) == 0 {
+			secret = encrypt.JoinSecret(uploadInit.Secret, key)
+		}
+	}
+
+	hash := sha256.New()
+	body = io.TeeReader(body, hash)
+
+	uploadDone := make(chan error, 1)
+
+	discard := func() {
+		cleanupCtx, cancel := cleanupContext(ctx)
+		defer cancel()
+		if err := client.CleanupFailedUploadContext(cleanupCtx, uploadInit.FileID); err != nil {
+			fmt.Fprintln(ui, "Warning: Failed to cleanup incomplete upload:", err)
+		} else {
+			fmt.Fprintln(status, "✓ Incomplete upload removed")
+		}
+	}
+	cleanup := func() {
+		fmt.Fprintln(ui, "\n\n⚠️  Upload interrupted. Cleaning up...")
+		discard()
+	}
+
+	bar := progress.New("Uploading", -1)
+	client.SetProgress(bar)
+	startProgress(bar)
+
+	go func() {
+		parts, _, err := client.UploadStreamContext(ctx, uploadInit, body, cfg.UploadWorkers)
+		if err == nil {
+			err = client.CompleteUploadContext(ctx, uploadInit.FileID, uploadInit.UploadID, parts)
+		}
+		uploadDone <- err
+	}()
+
+	err = waitTransfer(ctx, uploadDone)
+	bar.Stop()
+	if ctx.Err() != nil {
+		cleanup()
+		exitCode = exitInterrupted
+		return
+	}
+	if err != nil {
+		fail("Upload failed", err)
+		cleanup()
+		return
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	fmt.Fprint(status, "⏳ Verifying upload...")
+	if err := client.VerifyUploadContext(ctx, uploadInit.FileID, sum); err != nil {
+		fmt.Fprintln(status)
+		failWith(exitVerify, "❌ Upload verification failed", err)
+		fmt.Fprintln(ui, "The file was not pushed. Please try again")
+		discard()
+		return
+	}
+
+	printUploadResult(cfg, uploadInit, secret, sum, opts)
+}
+
+func parseRecipients(keys []string) ([]*ecdh.PublicKey, error) {
+	var recipients []*ecdh.PublicKey
+	for _, k := range keys {
+		pub, err := encrypt.ParseRecipient(k)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, pub)
+	}
+	return recipients, nil
+}
+
+// resumeOrStartUpload picks up the journaled upload for localPath if the file
+// and the options shaping it are unchanged, otherwise it requests a new
+// upload and starts a journal. A resumed encrypted upload keeps the key
+// recorded in its journal. Uploads sent in a single request have nothing
+// to resume and get no journal.
+func resumeOrStartUpload(ctx context.Context, client *api.Client, localPath string, request api.UploadRequest, opts resume.Options, key []byte) (*resume.Journal, *api.UploadInitResponse, error) {
+	abs, err := filepath.Abs(localPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fingerprint, err := resume.Fingerprint(abs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	journal, err := resume.Load(abs)
+	if err != nil {
+		fmt.Fprintln(ui, "Warning: Ignoring unreadable upload journal:", err)
+		journal = nil
+	}
+
+	if journal != nil {
+		changed := journal.Options.Diff(opts)
+		switch {
+		case journal.Fingerprint != fingerprint || journal.Encrypted() != request.Encrypted:
+			fmt.Fprintln(status, "File or encryption changed since the last attempt, starting over.")
+			_ = client.CleanupFailedUploadContext(ctx, journal.FileID)
+		case len(changed) > 0:
+			// The server kept the first attempt's settings, resuming would
+			// quietly ignore the new ones
+			fmt.Fprintf(ui, "Warning: %s changed since the last attempt, starting over.\n", strings.Join(changed, ", "))
+			_ = client.CleanupFailedUploadContext(ctx, journal.FileID)
+		default:
+			uploadInit, err := client.ResumeUploadContext(ctx, journal.FileID, journal.UploadID)
+			if err == nil {
+				// The server never re-sends the secret, only the journal has it
+				uploadInit.TinyCode = journal.TinyCode
+				uploadInit.Secret = journal.Secret
+				uploadInit.ExpiresAt = journal.ExpiresAt
+				fmt.Fprintf(status, "Resuming upload (%d parts already sent)\n", len(journal.Completed))
+				return journal, uploadInit, nil
+			}
+			fmt.Fprintln(ui, "Could not resume previous upload, starting over:", err)
+		}
+		_ = journal.Remove()
+	}
+
+	uploadInit, err := client.RequestUploadWithContext(ctx, request)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !uploadInit.IsMultipart() {
+		return nil, uploadInit, nil
+	}
+
+	journal = resume.New(abs, fingerprint, opts, uploadInit)
+	if key != nil {
+		if err := journal.SetKey(encrypt.EncodeKey(key)); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := journal.Save(); err != nil {
+		return nil, nil, err
+	}
+	return journal, uploadInit, nil
 }
 
 //
@@ -272,19 +736,23 @@ func handlePush(cfg *config.Config, filepath string) {
 //  DELETE
 // ------------------------------------------------------------
 //
-func handleDelete(cfg *config.Config, tiny string) {
-    client := api.New(cfg)
 
-    if err := client.DeleteFile(tiny); err != nil {
-		if strings.Contains(err.Error(), "invalid subscription") {
-			fmt.Println("Error: Unauthorized.\nTo manage your subscription, visit: https://bucketlabs.org/auth")
+func handleDelete(ctx context.Context, cfg *config.Config, tiny string) {
+	client := newClient(cfg)
+
+	if err := client.DeleteFileContext(ctx, tiny); err != nil {
+		if errors.Is(err, api.ErrUnauthorized) {
+			failWith(exitAuth, "Error: Unauthorized.\nTo manage your subscription, visit: https://bucketlabs.org/auth", nil)
 		} else {
-			fmt.Println("Error:", err)
+			fail("Error", err)
 		}
-        return
-    }
+		return
+	}
 
-    fmt.Println("Deleted:", tiny)
+	forgetIDs(cfg)
+	printer.Result(map[string]string{"deleted": tiny}, func() {
+		fmt.Fprintln(ui, "Deleted:", tiny)
+	})
 }
 
 //
@@ -292,73 +760,312 @@ func handleDelete(cfg *config.Config, tiny string) {
 //  PULL
 // ------------------------------------------------------------
 //
-func handlePull(cfg *config.Config, tinyURL string) {
-    tiny := api.ExtractTinyCode(tinyURL)
-    secret := readSecret("Enter secret: ")
 
-    client := api.New(cfg)
+type pullOptions struct {
+	connections int    // Parallel Range connections for large objects
+	extract     bool   // Unpack a pushed archive into the current directory
+	output      string // Save as this path instead of the pushed name, "-" for stdout
+}
+
+func handlePull(ctx context.Context, cfg *config.Config, tinyURL string, opts pullOptions) {
+	tiny := api.ExtractTinyCode(tinyURL)
+	secret, encodedKey := encrypt.SplitSecret(readSecret("Enter secret: "))
+
+	var key []byte
+	if encodedKey != "" {
+		var err error
+		if key, err = encrypt.DecodeKey(encodedKey); err != nil {
+			fail("Secret error", err)
+			return
+		}
+	}
+
+	client := newClient(cfg)
+
+	// authenticate presigned URL
+	auth, err := client.AuthorizeDownloadContext(ctx, tiny, secret)
+	if err != nil {
+		fail("Download auth failed", err)
+		return
+	}
+
+	// download object, re-authenticating if the presigned URL expires mid-transfer
+	// without counting it as another download
+	reauth := func() (string, error) {
+		renewed, err := client.RenewDownloadContext(ctx, tiny, secret, auth.DownloadID)
+		if err != nil {
+			return "", err
+		}
+		return renewed.DownloadURL, nil
+	}
+
+	// The server counted the pull when it authorized it, this only lets it
+	// know the file arrived intact
+	confirm := func() {
+		_ = client.ConfirmDownloadContext(ctx, tiny, secret)
+		if auth.Burn {
+			fmt.Fprintln(status, "🔥 Burn after reading: the file is now deleted from the bucket")
+		} else if auth.DownloadsRemaining != nil {
+			fmt.Fprintf(status, "Downloads remaining: %d\n", *auth.DownloadsRemaining)
+		}
+	}
 
-    // authenticate presigned URL
-    downloadURL, filename, err := client.AuthDownload(tiny, secret)  
-    if err != nil {
-        fmt.Println("Download auth failed:", err)
-        return
-    }
+	if opts.output == "-" {
+		pullToStdout(ctx, client, auth, key, reauth, confirm)
+		return
+	}
 
-    // Start spinner
-    spinnerDone := make(chan bool)
-    downloadDone := make(chan error, 1)
-    
-    go showSpinner(spinnerDone, "Downloading")
+	filename := auth.Filename
+	if opts.output != "" {
+		filename = opts.output
+	}
+	if filename == "" {
+		filename = "downloaded.file"
+	}
+
+	// Start progress, counting anything a previous attempt already saved
+	downloadDone := make(chan error, 1)
 
-    // download object
-    go func() {
-        _, err := client.DownloadFile(downloadURL, filename)
-        downloadDone <- err
-    }()
+	bar := progress.New("Downloading", downloadTotal(auth))
+	if part, err := os.Stat(filename + api.PartialSuffix); err == nil {
+		bar.Add(part.Size())
+	}
+	client.SetProgress(bar)
+	startProgress(bar)
+
+	go func() {
+		_, err := client.DownloadContext(ctx, api.DownloadRequest{
+			URL:         auth.DownloadURL,
+			Filename:    filename,
+			ID:          tiny,
+			SHA256:      auth.SHA256,
+			Connections: opts.connections,
+			Reauth:      reauth,
+		})
+		downloadDone <- err
+	}()
+
+	// Wait for download
+	err = waitTransfer(ctx, downloadDone)
+	bar.Stop()
+
+	if ctx.Err() != nil {
+		fmt.Fprintln(ui, "\n\n⚠️  Download interrupted.")
+		if _, statErr := os.Stat(filename + api.PartialSuffix); statErr == nil {
+			fmt.Fprintln(ui, "Partial download kept. Run the same pull again to continue.")
+		}
+		exitCode = exitInterrupted
+		return
+	}
 
-    // Wait for download
-    err = <-downloadDone
-    spinnerDone <- true
+	if errors.Is(err, api.ErrChecksumMismatch) {
+		fmt.Fprintln(status)
+		fail("❌ Integrity check failed", err)
+		fmt.Fprintln(ui, "The corrupted download was discarded. Please try again")
+		return
+	}
+	if err != nil {
+		fail("Download failed", err)
+		if _, statErr := os.Stat(filename + api.PartialSuffix); statErr == nil {
+			fmt.Fprintln(ui, "Partial download kept. Run the same pull again to continue.")
+		}
+		return
+	}
 
-    if err != nil {
-        fmt.Println("Download failed:", err)
-        return
-    }
+	if auth.Encrypted {
+		identities, err := encrypt.LoadIdentities(config.IdentitiesPath())
+		if err != nil {
+			fmt.Fprintln(ui, "Identity error:", err)
+		}
 
-    fmt.Println("\n✓ Downloaded:", filename)
+		fmt.Fprint(status, "🔓 Decrypting...")
+		if err := encrypt.DecryptFile(filename, key, identities); err != nil {
+			fmt.Fprintln(status)
+			failWith(exitVerify, "❌ Decryption failed", err)
+			fmt.Fprintln(ui, "The encrypted file was saved as-is:", filename)
+			return
+		}
+	}
+
+	fmt.Fprintln(status, "\n✓ Downloaded:", filename)
+	if auth.SHA256 != "" {
+		fmt.Fprintln(status, "✓ SHA256 verified:", auth.SHA256)
+	}
+
+	confirm()
+
+	result := pullResult{Filename: filename, SHA256: auth.SHA256, Encrypted: auth.Encrypted}
+	if info, err := os.Stat(filename); err == nil {
+		result.SizeBytes = info.Size()
+	}
+
+	if opts.extract {
+		if err := extractArchive(filename); err != nil {
+			fail("❌ Extract failed", err)
+			fmt.Fprintln(ui, "The archive was kept:", filename)
+			return
+		}
+		fmt.Fprintln(status, "✓ Extracted into current directory")
+		result.Extracted = true
+	}
+
+	printer.Result(result, func() {})
+}
+
+// pullResult is what --json and --output report for a finished pull
+type pullResult struct {
+	Filename  string `json:"filename"`
+	SizeBytes int64  `json:"size_bytes"`
+	SHA256    string `json:"sha256,omitempty"`
+	Encrypted bool   `json:"encrypted"`
+	Extracted bool   `json:"extracted"`
+}
+
+// pullToStdout streams the object to stdout, decrypting on the fly if needed.
+// Nothing touches the disk, so there is no .part file to resume from later.
+func pullToStdout(ctx context.Context, client *api.Client, auth *api.DownloadAuthResponse, key []byte, reauth func() (string, error), confirm func()) {
+	bar := progress.New("Downloading", downloadTotal(auth))
+	client.SetProgress(bar)
+	startProgress(bar)
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(client.StreamDownloadContext(ctx, api.DownloadRequest{
+			URL:    auth.DownloadURL,
+			SHA256: auth.SHA256,
+			Reauth: reauth,
+		}, pw))
+	}()
+
+	var err error
+	if auth.Encrypted {
+		identities, idErr := encrypt.LoadIdentities(config.IdentitiesPath())
+		if idErr != nil {
+			fmt.Fprintln(os.Stderr, "Identity error:", idErr)
+		}
+		err = encrypt.Decrypt(os.Stdout, pr, key, identities)
+	} else {
+		_, err = io.Copy(os.Stdout, pr)
+	}
+	pr.CloseWithError(err)
+	bar.Stop()
+
+	if err != nil {
+		fail("Download failed", err)
+		return
+	}
+	fmt.Fprintln(status, "✓ Downloaded:", auth.Filename)
+	confirm()
+}
+
+// downloadTotal is the object size for the progress bar, or -1 if the
+// server did not say
+func downloadTotal(auth *api.DownloadAuthResponse) int64 {
+	if auth.SizeBytes > 0 {
+		return auth.SizeBytes
+	}
+	return -1
+}
+
+// extractArchive unpacks a pulled archive next to it and removes it
+func extractArchive(filename string) error {
+	if !archive.IsArchive(filename) {
+		return fmt.Errorf("%s is not a bucket archive", filename)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := archive.Extract(f, "."); err != nil {
+		return err
+	}
+
+	f.Close()
+	return os.Remove(filename)
 }
 
 //
 // ------------------------------------------------------------
-//  LIST
+//  KEYGEN
 // ------------------------------------------------------------
 //
-func handleList(cfg *config.Config) {
-	client := api.New(cfg)
 
-	files, err := client.ListFiles()
+func handleKeygen() {
+	identity, err := encrypt.GenerateIdentity()
 	if err != nil {
-		fmt.Println("List failed:", err)
+		fail("Keygen failed", err)
 		return
 	}
 
-	if len(files) == 0 {
-		fmt.Println("No files in your bucket.")
+	path := config.IdentitiesPath()
+	if err := encrypt.AppendIdentity(path, identity); err != nil {
+		fail("Keygen failed", err)
+		return
+	}
+
+	recipient := encrypt.FormatRecipient(identity.PublicKey())
+	printer.Result(map[string]string{"identities": path, "public_key": recipient}, func() {
+		fmt.Fprintln(ui, "Identity saved to:", path)
+		fmt.Fprintln(ui, "Public key:", recipient)
+		fmt.Fprintln(ui)
+		fmt.Fprintln(ui, "Share the public key. Others can then send you files with:")
+		fmt.Fprintln(ui, "  bucket push --to <public key> <file>")
+	})
+}
+
+//
+// ------------------------------------------------------------
+//  LIST
+// ------------------------------------------------------------
+//
+
+func handleList(ctx context.Context, cfg *config.Config) {
+	client := newClient(cfg)
+
+	files, err := client.ListFilesContext(ctx)
+	if err != nil {
+		fail("List failed", err)
 		return
 	}
 
-	fmt.Printf("%-16s %-20s %-12s %-24s\n", "ID", "Filename", "Size", "Expires")
-	fmt.Println(strings.Repeat("-", 70))
+	if files == nil {
+		files = []api.FileInfo{}
+	}
+	rememberIDs(cfg, files)
+
+	printer.Result(files, func() {
+		if len(files) == 0 {
+			fmt.Fprintln(ui, "No files in your bucket.")
+			return
+		}
+
+		fmt.Fprintf(ui, "%-16s %-20s %-12s %-24s %s\n", "ID", "Filename", "Size", "Expires", "Downloads")
+		fmt.Fprintln(ui, strings.Repeat("-", 88))
+
+		for _, f := range files {
+			fmt.Fprintf(ui, "%-16s %-20s %-12s %-24s %s\n",
+				f.TinyCode,
+				f.Filename,
+				humanSize(f.SizeBytes),
+				f.ExpiresAt,
+				downloadsLeft(f),
+			)
+		}
+	})
+}
 
-	for _, f := range files {
-		fmt.Printf("%-10s %-20s %-12s %-24s\n",
-			f.TinyCode,
-			f.Filename,
-			humanSize(f.SizeBytes),
-			f.ExpiresAt,
-		)
+// downloadsLeft describes how many more times f can be pulled
+func downloadsLeft(f api.FileInfo) string {
+	switch {
+	case f.Burn:
+		return "once (burn)"
+	case f.DownloadsRemaining == nil:
+		return "unlimited"
 	}
+	return strconv.Itoa(*f.DownloadsRemaining)
 }
 
 //
@@ -366,6 +1073,7 @@ func handleList(cfg *config.Config) {
 //  HELPER FUNCS
 // ------------------------------------------------------------
 //
+
 func generateDeviceName(email string) string {
 	prefix := strings.Split(email, "@")[0]
 	prefix = strings.Split(prefix, ".")[0]
@@ -416,59 +1124,151 @@ func humanSize(n int64) string {
 	return fmt.Sprintf("%.2f GB", gb)
 }
 
-func deleteJson() {
-	// DELETE CONFIG
-	configFile := config.Path() 
-	os.Remove(configFile)
-	fmt.Println("Logged out. API key cleared.")
+// waitTransfer waits for a transfer goroutine to report back. Once ctx is
+// cancelled its requests abort, but a read blocked on stdin never returns,
+// so it is only given transferGrace to finish.
+func waitTransfer(ctx context.Context, done <-chan error) error {
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(transferGrace):
+		return ctx.Err()
+	}
+}
+
+// cleanupContext is for removing what an interrupted command left on the
+// server: it outlives ctx, but not by long
+func cleanupContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+}
+
+// newClient is api.New plus what the global flags ask of every client
+func newClient(cfg *config.Config) *api.Client {
+	client := api.New(cfg)
+
+	timeouts := api.DefaultTimeouts
+	timeouts.Connect = globals.connectTimeout
+	timeouts.Stall = globals.stallTimeout
+	client.SetTimeouts(timeouts)
+
+	if globals.verbose {
+		client.SetLog(os.Stderr)
+	}
+	return client
+}
+
+// startProgress shows bar unless --quiet asked for silence. The bar still
+// counts bytes either way.
+func startProgress(bar *progress.Bar) {
+	if !globals.quiet {
+		bar.Start()
+	}
+}
+
+// clearLogin forgets the profile's key and device, keeping only where it
+// points so the next login goes to the same server
+func clearLogin(cfg *config.Config) {
+	*cfg = config.Config{
+		APIBase:       cfg.APIBase,
+		UploadWorkers: cfg.UploadWorkers,
+		Profile:       cfg.Profile,
+	}
+	if err := config.Save(cfg); err != nil {
+		fail("Config error", err)
+		return
+	}
+	printer.Result(map[string]bool{"logged_out": true}, func() {
+		fmt.Fprintln(ui, "Logged out. API key cleared.")
+	})
 }
 
 func readSecret(prompt string) string {
-	fmt.Print(prompt)
+	// Prompt on stderr so it never ends up in piped output
+	fmt.Fprint(os.Stderr, prompt)
 
 	byteSecret, _ := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 
 	return strings.TrimSpace(string(byteSecret))
 }
 
+// askPassphrase unlocks the encrypted credentials file, asking twice when
+// the file is new
+func askPassphrase(create bool) (string, error) {
+	if !create {
+		return readSecret("Credentials passphrase: "), nil
+	}
+
+	fmt.Fprintln(os.Stderr, "No OS keyring found, API keys will be kept in", config.CredentialsPath())
+	pass := readSecret("New credentials passphrase: ")
+	if pass == "" {
+		return "", errors.New("empty passphrase")
+	}
+	if readSecret("Repeat passphrase: ") != pass {
+		return "", errors.New("passphrases do not match")
+	}
+	return pass, nil
+}
+
 func readPassword() string {
-	fmt.Print("Password: ")
+	fmt.Fprint(os.Stderr, "Password: ")
 
 	bytePassword, _ := term.ReadPassword(int(os.Stdin.Fd()))
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 
 	return strings.TrimSpace(string(bytePassword))
 }
 
-func showSpinner(done chan bool, message string) {
-    spinners := []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
-    i := 0
-    for {
-        select {
-        case <-done:
-            fmt.Print("\r \r") // Clear the spinner line
-            return
-        default:
-            fmt.Printf("\r%s %s", spinners[i%len(spinners)], message)
-            i++
-            time.Sleep(100 * time.Millisecond)
-        }
-    }
-}
-
-func printHelp() {
-	fmt.Println(`bucket CLI - Secure File Sharing                    
-(c) Bucket Labs 2025 
-
-Commands:
-  bucket login   	       	Login 
-  bucket logout 		Logout 
-  bucket account 		View account info
-  bucket push <file>        	Upload a file
-  bucket pull <bURL>    	Download a file
-  bucket list               	List uploaded files
-  bucket del <id>		Delete file 
+func printHelp(w io.Writer) {
+	fmt.Fprintln(w, `bucket CLI - Secure File Sharing
+(c) Bucket Labs 2025
+
+Usage: bucket [global flags] <command> [flags] [arguments]
+
+Commands:`)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  bucket %s %s\t%s\n", c.name, c.args, c.summary)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, `
+Global flags:
+  --config <path>	Use another config file
+  --profile <name>	Use a saved profile instead of the default one
+  --api-base <url>	Talk to another API server for this run
+  --quiet		Print only results and errors, no progress
+  --verbose		Report retried requests on stderr
+  --json		Print results as JSON on stdout
+  --output <format>	Print results as text, json, yaml or table
+  --connect-timeout <d>	Give up connecting after d (default 30s)
+  --stall-timeout <d>	Retry a transfer that moved nothing for d (default 2m)
+
+Environment:
+  BUCKET_API_KEY	Use this API key without logging in, e.g. in CI
+  BUCKET_API_BASE	Same as --api-base
+  BUCKET_PROFILE	Same as --profile
+  BUCKET_CONFIG		Same as --config
+  BUCKET_UPLOAD_WORKERS	Parts uploaded at once by a multipart push
+
+Flags win over these, which win over the nearest .bucket.json (api_base,
+profile, upload_workers), which wins over the profile. See: bucket config show --origin
+
+Run 'bucket <command> --help' for a command's flags.
+
+Exit codes:
+  0  success			4  not found or expired
+  1  other error		5  quota exceeded
+  2  bad usage			6  network error
+  3  not logged in / auth	7  verification failed
+			      130  interrupted
 
 You must first create an account: https://bucketlabs.org/auth`)
 }
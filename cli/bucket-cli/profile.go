@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+var profileActions = []string{"list", "use", "add", "remove"}
+
+//
+// ------------------------------------------------------------
+//  PROFILES
+// ------------------------------------------------------------
+//
+
+func handleProfile(args []string) {
+	action, name := args[0], ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+
+	switch action {
+	case "list":
+		if name != "" {
+			failWith(exitUsage, "profile list takes no name", nil)
+			return
+		}
+	case "use", "add", "remove":
+		if name == "" {
+			failWith(exitUsage, fmt.Sprintf("profile %s needs a name", action), nil)
+			return
+		}
+		if err := config.ValidProfileName(name); err != nil {
+			failWith(exitUsage, "Profile error", err)
+			return
+		}
+	default:
+		failWith(exitUsage, fmt.Sprintf("Unknown profile action %q (want %s)", action, strings.Join(profileActions, ", ")), nil)
+		return
+	}
+
+	f, err := config.LoadFile()
+	if err != nil {
+		fail("Config error", err)
+		return
+	}
+
+	switch action {
+	case "list":
+		listProfiles(f, f.Active())
+	case "use":
+		useProfile(f, name)
+	case "add":
+		addProfile(f, name)
+	case "remove":
+		removeProfile(f, name)
+	}
+}
+
+// profileInfo is what --json and --output report per profile
+type profileInfo struct {
+	Name     string `json:"name"`
+	APIBase  string `json:"api_base"`
+	LoggedIn bool   `json:"logged_in"`
+	Default  bool   `json:"default"`
+	Active   bool   `json:"active"`
+}
+
+func listProfiles(f *config.File, active string) {
+	names := f.Names()
+	if _, ok := f.Profiles[f.DefaultProfile]; !ok {
+		// Never saved yet, but it is what a plain `bucket` uses
+		names = append([]string{f.DefaultProfile}, names...)
+	}
+
+	profiles := make([]profileInfo, 0, len(names))
+	for _, name := range names {
+		info := profileInfo{Name: name, APIBase: config.DefaultAPIBase, Default: name == f.DefaultProfile, Active: name == active}
+		if p := f.Profiles[name]; p != nil {
+			info.APIBase = orDefault(p.APIBase, config.DefaultAPIBase)
+			info.LoggedIn = p.APIKey != "" || p.APIKeyRef != ""
+		}
+		profiles = append(profiles, info)
+	}
+
+	printer.Result(profiles, func() {
+		tw := tabwriter.NewWriter(ui, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  NAME\tAPI\tLOGGED IN")
+		for _, p := range profiles {
+			mark := " "
+			if p.Active {
+				mark = "*"
+			}
+			fmt.Fprintf(tw, "%s %s\t%s\t%s\n", mark, p.Name, p.APIBase, yesNo(p.LoggedIn))
+		}
+		tw.Flush()
+	})
+}
+
+func useProfile(f *config.File, name string) {
+	if _, ok := f.Profiles[name]; !ok && name != f.DefaultProfile {
+		failWith(exitUsage, fmt.Sprintf("No profile named %q, create it with: bucket profile add %s", name, name), nil)
+		return
+	}
+
+	f.DefaultProfile = name
+	if err := f.Save(); err != nil {
+		fail("Config error", err)
+		return
+	}
+	printer.Result(map[string]string{"default_profile": name}, func() {
+		fmt.Fprintln(ui, "Default profile:", name)
+	})
+}
+
+// addProfile creates an empty profile. A --api-base given with it is saved
+// as the profile's server rather than used for one run.
+func addProfile(f *config.File, name string) {
+	if _, ok := f.Profiles[name]; ok {
+		failWith(exitUsage, fmt.Sprintf("Profile %q already exists", name), nil)
+		return
+	}
+
+	p := &config.Config{APIBase: config.DefaultAPIBase}
+	if globals.apiBase != "" {
+		p.APIBase = strings.TrimRight(globals.apiBase, "/")
+	}
+	f.Profiles[name] = p
+	if err := f.Save(); err != nil {
+		fail("Config error", err)
+		return
+	}
+
+	printer.Result(map[string]string{"profile": name, "api_base": p.APIBase}, func() {
+		fmt.Fprintf(ui, "Added profile %s (%s)\n", name, p.APIBase)
+		fmt.Fprintf(ui, "Log in with: bucket --profile %s login\n", name)
+	})
+}
+
+func removeProfile(f *config.File, name string) {
+	p, ok := f.Profiles[name]
+	if !ok {
+		failWith(exitUsage, fmt.Sprintf("No profile named %q", name), nil)
+		return
+	}
+	if name == f.DefaultProfile {
+		failWith(exitUsage, "Cannot remove the default profile, switch first with: bucket profile use <name>", nil)
+		return
+	}
+
+	if err := f.Remove(name); err != nil {
+		fail("Config error", err)
+		return
+	}
+	forgetIDs(&config.Config{Profile: name})
+
+	printer.Result(map[string]string{"removed": name}, func() {
+		fmt.Fprintln(ui, "Removed profile:", name)
+		if p.APIKey != "" || p.APIKeyRef != "" {
+			fmt.Fprintln(ui, "Note: its API key was not revoked on the server.")
+		}
+	})
+}
+
+// profileNames lists saved profiles for completion
+func profileNames() []string {
+	f, err := config.LoadFile()
+	if err != nil {
+		return nil
+	}
+	return f.Names()
+}
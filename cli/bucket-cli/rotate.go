@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+//
+// ------------------------------------------------------------
+//  ROTATE
+// ------------------------------------------------------------
+//
+
+func handleRotate(ctx context.Context, cfg *config.Config, tiny string, newTinyCode bool) {
+	if cfg.APIKey == "" {
+		failWith(exitAuth, "Not logged in. Run: bucket login", nil)
+		return
+	}
+
+	client := newClient(cfg)
+
+	rotated, err := client.RotateSecretContext(ctx, api.ExtractTinyCode(tiny), newTinyCode)
+	if err != nil {
+		fail("Rotate failed", err)
+		return
+	}
+	if newTinyCode {
+		forgetIDs(cfg)
+	}
+
+	// The key never left this machine, so the server cannot hand it back.
+	// Recipient uploads keep the key in the file, the new secret is enough.
+	keyRequired := rotated.Encrypted && !rotated.Recipients
+
+	printShare("✓ Secret rotated, the old link no longer works", pushResult{
+		ID:          rotated.TinyCode,
+		URL:         shareURL(cfg, rotated.TinyCode),
+		Secret:      rotated.Secret,
+		ExpiresAt:   rotated.ExpiresAt,
+		KeyRequired: keyRequired,
+	})
+
+	if keyRequired {
+		fmt.Fprintln(ui)
+		fmt.Fprintln(ui, "Note: if this file was pushed with --encrypt, append the original")
+		fmt.Fprintln(ui, "#key part of the old secret to the new one before sharing it.")
+	}
+}
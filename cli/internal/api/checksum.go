@@ -0,0 +1,185 @@
+package api
+
+import (
+	"container/heap"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+)
+
+// maxReadAhead bounds the out-of-order bytes a Hasher keeps in memory,
+// anything further ahead goes to a temporary file until its turn comes
+var maxReadAhead int64 = 64 << 20
+
+// Hasher computes the SHA-256 of an upload from the reads that send it.
+// Bytes are hashed in order: parts read ahead of the hash, as parallel
+// multipart workers do, are held until everything before them has been
+// hashed, and re-reads by retries are not counted twice.
+type Hasher struct {
+	src  io.ReaderAt
+	size int64
+
+	mu       sync.Mutex
+	hash     hash.Hash
+	next     int64  // Bytes hashed so far
+	ahead    pieces // Bytes read past next, waiting their turn
+	buffered int64  // Bytes of ahead held in memory
+	spill    *os.File
+	err      error
+	closed   bool
+}
+
+// piece is a range read ahead of the hash. Its bytes are in data, in the
+// spill file at the same offset, or still only in the source for ranges
+// sent by an earlier attempt.
+type piece struct {
+	off, end int64
+	data     []byte
+	source   bool
+}
+
+// pieces is a min-heap on offset
+type pieces []piece
+
+func (p pieces) Len() int           { return len(p) }
+func (p pieces) Less(i, j int) bool { return p[i].off < p[j].off }
+func (p pieces) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p *pieces) Push(x any)        { *p = append(*p, x.(piece)) }
+func (p *pieces) Pop() any {
+	old := *p
+	x := old[len(old)-1]
+	*p = old[:len(old)-1]
+	return x
+}
+
+// NewHasher wraps the first size bytes of src; upload from the Hasher
+// instead of src and Close it when the upload is over
+func NewHasher(src io.ReaderAt, size int64) *Hasher {
+	return &Hasher{src: src, size: size, hash: sha256.New()}
+}
+
+func (h *Hasher) ReadAt(p []byte, off int64) (int, error) {
+	n, err := h.src.ReadAt(p, off)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed || h.err != nil || n == 0 {
+		return n, err
+	}
+
+	end := off + int64(n)
+	switch {
+	case end <= h.next:
+		// A retry of bytes already hashed
+	case off <= h.next:
+		h.hash.Write(p[h.next-off : n])
+		h.next = end
+		h.drain()
+	default:
+		h.hold(p[:n], off)
+	}
+	return n, err
+}
+
+// Skip marks a range sent by an earlier attempt, which this upload will
+// not read. It is read from the source when the hash reaches it.
+func (h *Hasher) Skip(off, length int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed || h.err != nil || length <= 0 {
+		return
+	}
+	heap.Push(&h.ahead, piece{off: off, end: off + length, source: true})
+	h.drain()
+}
+
+// hold keeps bytes read ahead of the hash, in memory while there is room
+func (h *Hasher) hold(p []byte, off int64) {
+	pc := piece{off: off, end: off + int64(len(p))}
+	if h.buffered+int64(len(p)) <= maxReadAhead {
+		pc.data = append([]byte(nil), p...)
+		h.buffered += int64(len(p))
+	} else {
+		if h.spill == nil {
+			if h.spill, h.err = os.CreateTemp("", "bucket-checksum-*"); h.err != nil {
+				return
+			}
+		}
+		if _, h.err = h.spill.WriteAt(p, off); h.err != nil {
+			return
+		}
+	}
+	heap.Push(&h.ahead, pc)
+}
+
+// drain hashes the held pieces that have become contiguous with next
+func (h *Hasher) drain() {
+	for h.err == nil && len(h.ahead) > 0 && h.ahead[0].off <= h.next {
+		pc := heap.Pop(&h.ahead).(piece)
+		h.buffered -= int64(len(pc.data))
+		if pc.end <= h.next {
+			continue
+		}
+
+		switch {
+		case pc.data != nil:
+			h.hash.Write(pc.data[h.next-pc.off:])
+		case pc.source:
+			_, h.err = io.Copy(h.hash, io.NewSectionReader(h.src, h.next, pc.end-h.next))
+		default:
+			_, h.err = io.Copy(h.hash, io.NewSectionReader(h.spill, h.next, pc.end-h.next))
+		}
+		h.next = pc.end
+	}
+}
+
+// Sum returns the hex SHA-256 of all size bytes. It fails if the upload
+// left any of them unread.
+func (h *Hasher) Sum() (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return "", h.err
+	}
+	if h.next < h.size {
+		return "", fmt.Errorf("checksum covers %d of %d bytes", h.next, h.size)
+	}
+	return hex.EncodeToString(h.hash.Sum(nil)), nil
+}
+
+// Close drops the held pieces and removes the temporary file, if any.
+// Reads still go through to the source but are no longer hashed.
+func (h *Hasher) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	h.ahead, h.buffered = nil, 0
+	if h.spill == nil {
+		return nil
+	}
+	name := h.spill.Name()
+	h.spill.Close()
+	h.spill = nil
+	return os.Remove(name)
+}
+
+// HashFile returns the hex SHA-256 of a local file
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// countingReaderAt counts the bytes read from it
+type countingReaderAt struct {
+	r    io.ReaderAt
+	mu   sync.Mutex
+	read int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.mu.Lock()
+	c.read += int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+// readPart reads [off, off+length) through h in small chunks, as a request
+// body is sent
+func readPart(t *testing.T, h *Hasher, off, length int64) {
+	t.Helper()
+	if _, err := io.CopyBuffer(io.Discard, io.NewSectionReader(h, off, length), make([]byte, 1000)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHasherOutOfOrderParts(t *testing.T) {
+	data := randomBytes(t, 10*4096+123)
+	const part = 4096
+	partRange := func(i int64) (int64, int64) {
+		return i * part, min(part, int64(len(data))-i*part)
+	}
+
+	for name, order := range map[string][]int64{
+		"in order":     {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		"reversed":     {10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+		"interleaved":  {1, 0, 3, 2, 5, 4, 7, 6, 9, 8, 10},
+		"last first":   {10, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		"with retries": {2, 0, 2, 1, 0, 5, 3, 4, 5, 10, 6, 7, 8, 9, 7},
+	} {
+		src := &countingReaderAt{r: bytes.NewReader(data)}
+		h := NewHasher(src, int64(len(data)))
+		for _, i := range order {
+			off, n := partRange(i)
+			readPart(t, h, off, n)
+		}
+
+		sum, err := h.Sum()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if sum != sha256Hex(data) {
+			t.Errorf("%s: Sum = %s, want %s", name, sum, sha256Hex(data))
+		}
+
+		// Sum works from the bytes that were sent, nothing is read twice
+		var sent int64
+		for _, i := range order {
+			_, n := partRange(i)
+			sent += n
+		}
+		if src.read != sent {
+			t.Errorf("%s: read %d bytes from the source, the upload sent %d", name, src.read, sent)
+		}
+		h.Close()
+	}
+}
+
+func TestHasherInterruptedRetry(t *testing.T) {
+	data := randomBytes(t, 3*4096)
+	h := NewHasher(bytes.NewReader(data), int64(len(data)))
+
+	// Part 2 fails halfway and is sent again, part 1 follows
+	readPart(t, h, 4096, 2000)
+	readPart(t, h, 4096, 4096)
+	readPart(t, h, 8192, 4096)
+	readPart(t, h, 0, 3000)
+	readPart(t, h, 0, 4096)
+
+	if sum, err := h.Sum(); err != nil || sum != sha256Hex(data) {
+		t.Errorf("Sum = %s, %v, want %s", sum, err, sha256Hex(data))
+	}
+}
+
+func TestHasherConcurrentParts(t *testing.T) {
+	data := randomBytes(t, 64*1024+7)
+	h := NewHasher(bytes.NewReader(data), int64(len(data)))
+	defer h.Close()
+
+	var wg sync.WaitGroup
+	for off := int64(0); off < int64(len(data)); off += 8192 {
+		wg.Add(1)
+		go func(off int64) {
+			defer wg.Done()
+			readPart(t, h, off, min(8192, int64(len(data))-off))
+		}(off)
+	}
+	wg.Wait()
+
+	if sum, err := h.Sum(); err != nil || sum != sha256Hex(data) {
+		t.Errorf("Sum = %s, %v, want %s", sum, err, sha256Hex(data))
+	}
+}
+
+func TestHasherSpillsReadAhead(t *testing.T) {
+	old := maxReadAhead
+	maxReadAhead = 5000
+	t.Cleanup(func() { maxReadAhead = old })
+	t.Setenv("TMPDIR", t.TempDir())
+
+	data := randomBytes(t, 4*4096)
+	h := NewHasher(bytes.NewReader(data), int64(len(data)))
+	for _, off := range []int64{3 * 4096, 2 * 4096, 4096, 0} {
+		readPart(t, h, off, 4096)
+	}
+	if sum, err := h.Sum(); err != nil || sum != sha256Hex(data) {
+		t.Errorf("Sum = %s, %v, want %s", sum, err, sha256Hex(data))
+	}
+
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(os.Getenv("TMPDIR"), "*")); len(left) > 0 {
+		t.Errorf("Close left %v behind", left)
+	}
+}
+
+func TestHasherSkippedParts(t *testing.T) {
+	data := randomBytes(t, 4*4096)
+	src := &countingReaderAt{r: bytes.NewReader(data)}
+	h := NewHasher(src, int64(len(data)))
+	defer h.Close()
+
+	// Parts 1 and 3 were stored by an earlier run
+	h.Skip(0, 4096)
+	h.Skip(2*4096, 4096)
+	readPart(t, h, 3*4096, 4096)
+	readPart(t, h, 4096, 4096)
+
+	if sum, err := h.Sum(); err != nil || sum != sha256Hex(data) {
+		t.Errorf("Sum = %s, %v, want %s", sum, err, sha256Hex(data))
+	}
+	if src.read != int64(len(data)) {
+		t.Errorf("read %d bytes from the source, want each byte once", src.read)
+	}
+}
+
+func TestHasherMissingBytes(t *testing.T) {
+	data := randomBytes(t, 2*4096)
+	h := NewHasher(bytes.NewReader(data), int64(len(data)))
+	defer h.Close()
+
+	readPart(t, h, 4096, 4096)
+	if _, err := h.Sum(); err == nil {
+		t.Error("Sum succeeded with the first part never read")
+	}
+}
@@ -2,13 +2,14 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
 )
@@ -31,6 +32,10 @@ type Client struct {
 	deviceID   string
 	deviceName string
 	http       *http.Client
+	timeouts   Timeouts
+	retry      RetryPolicy
+	progress   io.Writer
+	log        io.Writer
 }
 
 type DeleteResponse struct {
@@ -40,6 +45,17 @@ type DeleteResponse struct {
 type DownloadAuthResponse struct {
 	DownloadURL string `json:"download_url"`
 	Filename    string `json:"filename"`
+	SHA256      string `json:"sha256,omitempty"` // Checksum of the stored bytes
+	SizeBytes   int64  `json:"size_bytes,omitempty"`
+	Encrypted   bool   `json:"encrypted,omitempty"` // Pushed with --encrypt or --to, the bytes need decrypting
+
+	// Download limits once this download has been counted
+	DownloadsRemaining *int `json:"downloads_remaining,omitempty"`
+	Burn               bool `json:"burn_after_reading,omitempty"`
+
+	// Names the counted download, so RenewDownload can fetch a fresh URL for
+	// it without counting it again
+	DownloadID string `json:"download_id,omitempty"`
 }
 
 type FileInfo struct {
@@ -48,7 +64,33 @@ type FileInfo struct {
 	SizeBytes int64  `json:"size_bytes"`
 	TinyCode  string `json:"tiny_code"`
 	ExpiresAt string `json:"expires_at"`
-	SecretKey string `json:"download_secret_hash"`
+	SecretKey string `json:"-"` // download_secret_hash is not decoded so list and info --json cannot print it
+	SHA256    string `json:"sha256,omitempty"`
+
+	DownloadsRemaining *int `json:"downloads_remaining,omitempty"` // nil when unlimited
+	Burn               bool `json:"burn_after_reading,omitempty"`
+
+	// Only filled in by GetFile
+	UploadedAt      string `json:"uploaded_at,omitempty"`
+	DownloadCount   int    `json:"download_count"`
+	LastAccessedAt  string `json:"last_accessed_at,omitempty"` // Empty if never pulled
+	Encrypted       bool   `json:"encrypted"`
+	SecretRotatedAt string `json:"secret_rotated_at,omitempty"` // Empty if never rotated
+}
+
+type UploadRequest struct {
+	Filename  string `json:"filename"`
+	SizeBytes int64  `json:"size_bytes"` // Bytes that will be stored, after encryption
+	Multipart bool   `json:"multipart"`
+	Streaming bool   `json:"streaming,omitempty"` // Length unknown, parts are requested as they fill
+	Encrypted bool   `json:"encrypted,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"` // RFC 3339, server default when empty
+
+	// Encrypted for public keys, the secret carries no key
+	Recipients bool `json:"recipients,omitempty"`
+
+	MaxDownloads int  `json:"max_downloads,omitempty"` // 0 for unlimited
+	Burn         bool `json:"burn_after_reading,omitempty"`
 }
 
 type UploadInitResponse struct {
@@ -57,6 +99,11 @@ type UploadInitResponse struct {
 	TinyCode  string `json:"tiny_code"`
 	Secret    string `json:"secret"`
 	ExpiresAt string `json:"expires_at"`
+
+	// Multipart session (only set when the server negotiated one)
+	UploadID string       `json:"upload_id,omitempty"`
+	PartSize int64        `json:"part_size,omitempty"`
+	Parts    []UploadPart `json:"parts,omitempty"`
 }
 
 type TwoFARequiredError struct{}
@@ -64,20 +111,38 @@ type TwoFARequiredError struct{}
 // API functions
 func New(cfg *config.Config) *Client {
 	return &Client{
-		baseURL:    cfg.APIBase,
+		baseURL:    cfg.BaseURL(),
 		apiKey:     cfg.APIKey,
 		deviceID:   cfg.DeviceID,
 		deviceName: cfg.DeviceName,
-		http: &http.Client{
-			Timeout: 0,
-			Transport: &http.Transport{
-				ExpectContinueTimeout: 10 * time.Minute,
-				ResponseHeaderTimeout: 10 * time.Minute,
-			},
-		},
+		http:       &http.Client{Transport: newTransport(DefaultTimeouts)},
+		timeouts:   DefaultTimeouts,
+		retry:      DefaultRetryPolicy,
 	}
 }
 
+// SetProgress makes every transfer body report the bytes it moves to w,
+// e.g. a progress.Bar. Pass nil to stop reporting.
+func (c *Client) SetProgress(w io.Writer) {
+	c.progress = w
+}
+
+// untrack takes back n bytes already reported to c.progress that have to
+// be moved again
+func (c *Client) untrack(n int64) {
+	if bar, ok := c.progress.(interface{ Add(int64) }); ok && n > 0 {
+		bar.Add(-n)
+	}
+}
+
+// track wraps a transfer body so bytes read from it reach c.progress
+func (c *Client) track(r io.Reader) io.Reader {
+	if c.progress == nil {
+		return r
+	}
+	return io.TeeReader(r, c.progress)
+}
+
 func (e *TwoFARequiredError) Error() string {
 	return "2fa_required"
 }
@@ -111,20 +176,37 @@ func (c *Client) attachAuth(req *http.Request) {
 }
 
 func (c *Client) RequestUpload(filename string, size int64) (*UploadInitResponse, error) {
-	payload := fmt.Sprintf(`{"filename":"%s","size_bytes":%d}`, filename, size)
+	return c.RequestUploadContext(context.Background(), filename, size)
+}
+
+// RequestUploadContext is RequestUpload with a context for cancellation
+func (c *Client) RequestUploadContext(ctx context.Context, filename string, size int64) (*UploadInitResponse, error) {
+	return c.RequestUploadWithContext(ctx, UploadRequest{
+		Filename:  filename,
+		SizeBytes: size,
+		Multipart: size >= MultipartThreshold,
+	})
+}
 
-	req, _ := http.NewRequest("POST", c.baseURL+"/v1/upload/request", bytes.NewBuffer([]byte(payload)))
+func (c *Client) RequestUploadWith(upload UploadRequest) (*UploadInitResponse, error) {
+	return c.RequestUploadWithContext(context.Background(), upload)
+}
+
+// RequestUploadWithContext is RequestUploadWith with a context for cancellation
+func (c *Client) RequestUploadWithContext(ctx context.Context, upload UploadRequest) (*UploadInitResponse, error) {
+	payload, _ := json.Marshal(upload)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/upload/request", bytes.NewBuffer(payload))
 	c.attachAuth(req)
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("upload request failed: %s", b)
+		return nil, newError("upload request", resp)
 	}
 
 	var out UploadInitResponse
@@ -135,6 +217,11 @@ func (c *Client) RequestUpload(filename string, size int64) (*UploadInitResponse
 }
 
 func (c *Client) UploadFile(url, localPath string) error {
+	return c.UploadFileContext(context.Background(), url, localPath)
+}
+
+// UploadFileContext is UploadFile with a context for cancellation
+func (c *Client) UploadFileContext(ctx context.Context, url, localPath string) error {
 	f, err := os.Open(localPath)
 	if err != nil {
 		return err
@@ -145,157 +232,306 @@ func (c *Client) UploadFile(url, localPath string) error {
 	if err != nil {
 		return err
 	}
-	size := stat.Size()
 
-	req, _ := http.NewRequest("PUT", url, f)
+	return c.UploadReaderContext(ctx, url, f, stat.Size())
+}
+
+// UploadReader PUTs exactly size bytes from body to a presigned URL
+func (c *Client) UploadReader(url string, body io.Reader, size int64) error {
+	return c.UploadReaderContext(context.Background(), url, body, size)
+}
+
+// UploadReaderContext is UploadReader with a context for cancellation
+func (c *Client) UploadReaderContext(ctx context.Context, url string, body io.Reader, size int64) error {
+	ctx, guard := c.guard(ctx)
+	defer guard.stop()
+
+	wrap := func(r io.Reader) io.Reader { return guard.reader(c.track(r)) }
+	req, _ := http.NewRequestWithContext(ctx, "PUT", url, wrap(body))
+	c.rewindable(req, body, wrap)
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.ContentLength = size
 	req.Header.Set("Content-Length", fmt.Sprintf("%d", size))
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return err
+		return guard.err(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("upload failed: %s", b)
+		return newError("upload", resp)
 	}
 
 	return nil
 }
 
-func (c *Client) VerifyUpload(fileID string) error {
-	payload := fmt.Sprintf(`{"file_id":"%s"}`, fileID)
+// VerifyUpload asks the server to confirm the stored object, including that
+// its SHA-256 matches the checksum computed while pushing
+func (c *Client) VerifyUpload(fileID, sha256 string) error {
+	return c.VerifyUploadContext(context.Background(), fileID, sha256)
+}
+
+// VerifyUploadContext is VerifyUpload with a context for cancellation
+func (c *Client) VerifyUploadContext(ctx context.Context, fileID, sha256 string) error {
+	payload := fmt.Sprintf(`{"file_id":"%s","sha256":"%s"}`, fileID, sha256)
 
-	req, _ := http.NewRequest("POST", c.baseURL+"/v1/upload/verify", bytes.NewBuffer([]byte(payload)))
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/upload/verify", bytes.NewBuffer([]byte(payload)))
 	c.attachAuth(req)
+	markIdempotent(req)
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("upload verification failed: %s", b)
+		return newError("upload verification", resp)
 	}
 
 	return nil
 }
 
 func (c *Client) CleanupFailedUpload(fileID string) error {
+	return c.CleanupFailedUploadContext(context.Background(), fileID)
+}
+
+// CleanupFailedUploadContext is CleanupFailedUpload with a context for cancellation
+func (c *Client) CleanupFailedUploadContext(ctx context.Context, fileID string) error {
 	payload := fmt.Sprintf(`{"file_id":"%s"}`, fileID)
 
-	req, _ := http.NewRequest("POST", c.baseURL+"/v1/upload/cleanup", bytes.NewBuffer([]byte(payload)))
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/upload/cleanup", bytes.NewBuffer([]byte(payload)))
 	c.attachAuth(req)
+	markIdempotent(req)
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("cleanup failed: %s", b)
+		return newError("cleanup", resp)
 	}
 
 	return nil
 }
 
 func (c *Client) AuthDownload(tiny, secret string) (string, string, error) {
+	return c.AuthDownloadContext(context.Background(), tiny, secret)
+}
+
+// AuthDownloadContext is AuthDownload with a context for cancellation
+func (c *Client) AuthDownloadContext(ctx context.Context, tiny, secret string) (string, string, error) {
+	out, err := c.AuthorizeDownloadContext(ctx, tiny, secret)
+	if err != nil {
+		return "", "", err
+	}
+	return out.DownloadURL, out.Filename, nil
+}
+
+// AuthorizeDownload is AuthDownload returning the full response, including
+// the checksum a pull should verify against. The server counts the download
+// against the file's limit here and refuses once it is reached, so the
+// request is never retried: a lost response must not count twice.
+func (c *Client) AuthorizeDownload(tiny, secret string) (*DownloadAuthResponse, error) {
+	return c.AuthorizeDownloadContext(context.Background(), tiny, secret)
+}
+
+// AuthorizeDownloadContext is AuthorizeDownload with a context for cancellation
+func (c *Client) AuthorizeDownloadContext(ctx context.Context, tiny, secret string) (*DownloadAuthResponse, error) {
 	body := fmt.Sprintf(`{"tiny":"%s","secret":"%s"}`, tiny, secret)
 
-	req, _ := http.NewRequest("POST", c.baseURL+"/v1/download/auth", bytes.NewBuffer([]byte(body)))
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/download/auth", bytes.NewBuffer([]byte(body)))
 	c.attachAuth(req)
 
-	resp, err := c.http.Do(req)
+	return c.downloadAuth(req)
+}
+
+// RenewDownload presigns a fresh URL for a download AuthorizeDownload
+// already counted, for when the first one expires mid-transfer
+func (c *Client) RenewDownload(tiny, secret, downloadID string) (*DownloadAuthResponse, error) {
+	return c.RenewDownloadContext(context.Background(), tiny, secret, downloadID)
+}
+
+// RenewDownloadContext is RenewDownload with a context for cancellation
+func (c *Client) RenewDownloadContext(ctx context.Context, tiny, secret, downloadID string) (*DownloadAuthResponse, error) {
+	body := fmt.Sprintf(`{"tiny":"%s","secret":"%s","download_id":"%s"}`, tiny, secret, downloadID)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/download/auth", bytes.NewBuffer([]byte(body)))
+	c.attachAuth(req)
+	markIdempotent(req)
+
+	return c.downloadAuth(req)
+}
+
+func (c *Client) downloadAuth(req *http.Request) (*DownloadAuthResponse, error) {
+	resp, err := c.do(req)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return "", "", fmt.Errorf("auth failed: %s", b)
+		return nil, newError("auth", resp)
 	}
 
-	var out struct {
-		DownloadURL string `json:"download_url"`
-		Filename    string `json:"filename"`
-	}
+	var out DownloadAuthResponse
 	json.NewDecoder(resp.Body).Decode(&out)
-	return out.DownloadURL, out.Filename, nil
+	return &out, nil
 }
 
-func (c *Client) DownloadFile(url string, suggestedFilename string) (string, error) {
-	resp, err := c.http.Get(url)
+// ConfirmDownload tells the server a pull finished and verified. It is
+// informational only, the download was counted when it was authorized.
+func (c *Client) ConfirmDownload(tiny, secret string) error {
+	return c.ConfirmDownloadContext(context.Background(), tiny, secret)
+}
+
+// ConfirmDownloadContext is ConfirmDownload with a context for cancellation
+func (c *Client) ConfirmDownloadContext(ctx context.Context, tiny, secret string) error {
+	body := fmt.Sprintf(`{"tiny":"%s","secret":"%s"}`, tiny, secret)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/download/complete", bytes.NewBuffer([]byte(body)))
+	c.attachAuth(req)
+
+	resp, err := c.do(req)
 	if err != nil {
-		return "", err
+		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode >= 300 {
-		b, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("download failed: %s", b)
+	if resp.StatusCode != 200 {
+		return newError("download confirmation", resp)
 	}
 
-	// Use suggested filename from server
-	filename := suggestedFilename
-	if filename == "" {
-		filename = "downloaded.file"
-	}
+	return nil
+}
 
-	out, err := os.Create(filename)
+type RotateResponse struct {
+	TinyCode  string `json:"tiny_code"`
+	Secret    string `json:"secret"`
+	ExpiresAt string `json:"expires_at"`
+	Encrypted bool   `json:"encrypted"`
+
+	Recipients bool `json:"recipients,omitempty"` // As given to UploadRequest
+}
+
+// RotateSecret replaces a file's download secret, and with newTinyCode its
+// tiny code too. The old secret and code stop working immediately.
+func (c *Client) RotateSecret(tiny string, newTinyCode bool) (*RotateResponse, error) {
+	return c.RotateSecretContext(context.Background(), tiny, newTinyCode)
+}
+
+// RotateSecretContext is RotateSecret with a context for cancellation
+func (c *Client) RotateSecretContext(ctx context.Context, tiny string, newTinyCode bool) (*RotateResponse, error) {
+	payload := fmt.Sprintf(`{"tiny":"%s","new_tiny_code":%t}`, tiny, newTinyCode)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/files/rotate", bytes.NewBuffer([]byte(payload)))
+	c.attachAuth(req)
+
+	resp, err := c.do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	defer out.Close()
+	defer resp.Body.Close()
 
-	_, err = io.Copy(out, resp.Body)
-	return filename, err
+	if resp.StatusCode != 200 {
+		return nil, newError("rotate", resp)
+	}
+
+	var out RotateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
 func (c *Client) DeleteFile(tiny string) error {
+	return c.DeleteFileContext(context.Background(), tiny)
+}
+
+// DeleteFileContext is DeleteFile with a context for cancellation
+func (c *Client) DeleteFileContext(ctx context.Context, tiny string) error {
 	payload := fmt.Sprintf(`{"tiny":"%s"}`, tiny)
 
-	req, _ := http.NewRequest("POST", c.baseURL+"/v1/delete", bytes.NewBuffer([]byte(payload)))
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/delete", bytes.NewBuffer([]byte(payload)))
 	c.attachAuth(req)
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("object delete failed: %s", b)
+		return newError("object delete", resp)
 	}
 
 	return nil
 }
 
+// ExtendRequest moves a file's expiry. Set exactly one of ExtendBy (seconds
+// added to the current expiry) or ExpiresAt (RFC 3339).
+type ExtendRequest struct {
+	Tiny      string `json:"tiny"`
+	ExtendBy  int64  `json:"extend_by_seconds,omitempty"`
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+type ExtendResponse struct {
+	ExpiresAt string `json:"expires_at"`
+}
+
+// ExtendFile keeps a file around longer and returns its new expiry
+func (c *Client) ExtendFile(extend ExtendRequest) (*ExtendResponse, error) {
+	return c.ExtendFileContext(context.Background(), extend)
+}
+
+// ExtendFileContext is ExtendFile with a context for cancellation
+func (c *Client) ExtendFileContext(ctx context.Context, extend ExtendRequest) (*ExtendResponse, error) {
+	payload, _ := json.Marshal(extend)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/files/extend", bytes.NewBuffer(payload))
+	c.attachAuth(req)
+	if extend.ExpiresAt != "" {
+		markIdempotent(req) // Setting an absolute expiry twice is harmless, adding to it is not
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newError("extend", resp)
+	}
+
+	var out ExtendResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
 func (c *Client) ListFiles() ([]FileInfo, error) {
-	req, _ := http.NewRequest("GET", c.baseURL+"/v1/files", nil)
+	return c.ListFilesContext(context.Background())
+}
+
+// ListFilesContext is ListFiles with a context for cancellation
+func (c *Client) ListFilesContext(ctx context.Context) ([]FileInfo, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/files", nil)
 	c.attachAuth(req)
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		if resp.StatusCode == 403 {
-			return nil, fmt.Errorf("Invalid subscription type. Visit https://bucketlabs.org/auth to upgrade.")
-		} else {
-			b, _ := io.ReadAll(resp.Body)
-			return nil, fmt.Errorf("API response: %s", b)
-		}
+		return nil, newError("list", resp)
 	}
 
 	var files []FileInfo
@@ -303,12 +539,44 @@ func (c *Client) ListFiles() ([]FileInfo, error) {
 	return files, nil
 }
 
+// GetFile fetches the full metadata of one file
+func (c *Client) GetFile(tiny string) (*FileInfo, error) {
+	return c.GetFileContext(context.Background(), tiny)
+}
+
+// GetFileContext is GetFile with a context for cancellation
+func (c *Client) GetFileContext(ctx context.Context, tiny string) (*FileInfo, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/files/"+url.PathEscape(tiny), nil)
+	c.attachAuth(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newError("file info", resp)
+	}
+
+	var out FileInfo
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
 func ExtractTinyCode(url string) string {
 	parts := strings.Split(url, "/")
 	return parts[len(parts)-1]
 }
 
 func (c *Client) Login(email, password, otpCode string) (string, error) {
+	return c.LoginContext(context.Background(), email, password, otpCode)
+}
+
+// LoginContext is Login with a context for cancellation
+func (c *Client) LoginContext(ctx context.Context, email, password, otpCode string) (string, error) {
 	// -------------------------
 	// LOGIN (password check)
 	// -------------------------
@@ -319,22 +587,22 @@ func (c *Client) Login(email, password, otpCode string) (string, error) {
 
 	body1, _ := json.Marshal(loginPayload)
 
-	req1, _ := http.NewRequest(
+	req1, _ := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		c.baseURL+"/v1/account/login",
 		bytes.NewBuffer(body1),
 	)
 	req1.Header.Set("Content-Type", "application/json")
 
-	resp1, err := c.http.Do(req1)
+	resp1, err := c.do(req1)
 	if err != nil {
 		return "", err
 	}
 	defer resp1.Body.Close()
 
 	if resp1.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp1.Body)
-		return "", fmt.Errorf("login failed: %s", b)
+		return "", newError("login", resp1)
 	}
 
 	// -------------------------
@@ -353,14 +621,15 @@ func (c *Client) Login(email, password, otpCode string) (string, error) {
 
 	body2, _ := json.Marshal(keyPayload)
 
-	req2, _ := http.NewRequest(
+	req2, _ := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		c.baseURL+"/v1/account/keys",
 		bytes.NewBuffer(body2),
 	)
 	req2.Header.Set("Content-Type", "application/json")
 
-	resp2, err := c.http.Do(req2)
+	resp2, err := c.do(req2)
 	if err != nil {
 		return "", err
 	}
@@ -371,8 +640,7 @@ func (c *Client) Login(email, password, otpCode string) (string, error) {
 	}
 
 	if resp2.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp2.Body)
-		return "", fmt.Errorf("api key creation failed: %s", b)
+		return "", newError("api key creation", resp2)
 	}
 
 	var out struct {
@@ -386,18 +654,22 @@ func (c *Client) Login(email, password, otpCode string) (string, error) {
 }
 
 func (c *Client) FetchAccountInfo() (*AccountInfoResponse, error) {
-	req, _ := http.NewRequest("GET", c.baseURL+"/v1/account/info", nil)
+	return c.FetchAccountInfoContext(context.Background())
+}
+
+// FetchAccountInfoContext is FetchAccountInfo with a context for cancellation
+func (c *Client) FetchAccountInfoContext(ctx context.Context) (*AccountInfoResponse, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/v1/account/info", nil)
 	c.attachAuth(req)
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("account info failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		b, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("account info failed: %s", b)
+		return nil, newError("account info", resp)
 	}
 
 	var out AccountInfoResponse
@@ -409,7 +681,13 @@ func (c *Client) FetchAccountInfo() (*AccountInfoResponse, error) {
 }
 
 func (c *Client) Logout() error {
-	req, err := http.NewRequest(
+	return c.LogoutContext(context.Background())
+}
+
+// LogoutContext is Logout with a context for cancellation
+func (c *Client) LogoutContext(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		c.baseURL+"/v1/account/logout",
 		nil,
@@ -420,7 +698,7 @@ func (c *Client) Logout() error {
 
 	c.attachAuth(req)
 
-	resp, err := c.http.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -428,8 +706,7 @@ func (c *Client) Logout() error {
 
 	// Treat non-200 as warning, not fatal
 	if resp.StatusCode != http.StatusOK {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("logout failed: %s", b)
+		return newError("logout", resp)
 	}
 
 	return nil
@@ -0,0 +1,465 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// Suffix for an unfinished download, renamed away once it is complete
+	PartialSuffix = ".part"
+
+	// Suffix of the file next to a ".part" recording which object it holds
+	partialInfoSuffix = ".meta"
+
+	maxDownloadAttempts = 5
+)
+
+var (
+	// Presigned URL ran out; matches ErrExpired once no reauth can help
+	errLinkExpired = fmt.Errorf("download link %w", ErrExpired)
+
+	// A resumed download no longer matches the object, it restarts from 0
+	errObjectChanged = errors.New("object changed since the partial download, starting over")
+
+	// The object was replaced while it was being fetched
+	errObjectReplaced = errors.New("download failed: object changed during the download, try again")
+
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// DownloadRequest describes one object to fetch into a local file
+type DownloadRequest struct {
+	URL      string
+	Filename string
+
+	// Identifies the object across attempts, e.g. its tiny code. Presigned
+	// URLs change every time, so a ".part" file is only resumed when this,
+	// the size and the ETag all still match.
+	ID string
+
+	// Expected SHA-256 of the stored bytes; the file is only finalized if
+	// the download matches
+	SHA256 string
+
+	// Parallel Range connections, 0 or 1 for a single stream
+	Connections int
+
+	// Called for a fresh presigned URL when storage rejects the current one
+	Reauth func() (string, error)
+}
+
+// DownloadFile fetches url into suggestedFilename. Bytes are written to a
+// ".part" file first; if one is left over from an earlier attempt the
+// download continues from where it stopped using an HTTP Range request.
+func (c *Client) DownloadFile(url string, suggestedFilename string) (string, error) {
+	return c.DownloadFileContext(context.Background(), url, suggestedFilename)
+}
+
+// DownloadFileContext is DownloadFile with a context for cancellation
+func (c *Client) DownloadFileContext(ctx context.Context, url string, suggestedFilename string) (string, error) {
+	return c.DownloadContext(ctx, DownloadRequest{URL: url, Filename: suggestedFilename})
+}
+
+// Download is DownloadFile that also survives dropped connections and
+// presigned URLs expiring mid-transfer, can split the object over several
+// connections and verifies its checksum before renaming the ".part" file.
+func (c *Client) Download(d DownloadRequest) (string, error) {
+	return c.DownloadContext(context.Background(), d)
+}
+
+// DownloadContext is Download with a context for cancellation
+func (c *Client) DownloadContext(ctx context.Context, d DownloadRequest) (string, error) {
+	// Use suggested filename from server
+	filename := d.Filename
+	if filename == "" {
+		filename = "downloaded.file"
+	}
+	partPath := filename + PartialSuffix
+
+	// Holes left by a segmented download that was killed, never trusted
+	os.Remove(partPath + segmentsSuffix)
+
+	var err error
+	if d.Connections > 1 {
+		err = c.downloadSegmented(ctx, d.URL, partPath, d.ID, d.Connections, d.Reauth)
+	} else {
+		err = c.downloadStream(ctx, d.URL, partPath, d.ID, d.Reauth)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Never finalize a file whose length disagrees with the server's
+	stat, err := os.Stat(partPath)
+	if err != nil {
+		return "", err
+	}
+	info := loadPartialInfo(partPath)
+	if info == nil {
+		removePartial(partPath)
+		return "", errors.New("download failed: lost track of the partial file")
+	}
+	if info.Size >= 0 && stat.Size() != info.Size {
+		removePartial(partPath)
+		return "", fmt.Errorf("download incomplete: got %d of %d bytes", stat.Size(), info.Size)
+	}
+
+	if d.SHA256 != "" {
+		sum, err := HashFile(partPath)
+		if err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(sum, d.SHA256) {
+			// Resuming on top of bad bytes would never succeed
+			removePartial(partPath)
+			return "", fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, d.SHA256, sum)
+		}
+	}
+
+	if err := os.Rename(partPath, filename); err != nil {
+		return "", err
+	}
+	os.Remove(partPath + partialInfoSuffix)
+	return filename, nil
+}
+
+// partialInfo is kept next to a ".part" file so a later attempt only
+// appends to it if it still holds the start of the same object
+type partialInfo struct {
+	ID   string `json:"id,omitempty"`
+	Size int64  `json:"size"` // -1 until the server has said
+	ETag string `json:"etag,omitempty"`
+}
+
+// loadPartialInfo returns nil when there is no readable record
+func loadPartialInfo(partPath string) *partialInfo {
+	data, err := os.ReadFile(partPath + partialInfoSuffix)
+	if err != nil {
+		return nil
+	}
+	var info partialInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil
+	}
+	return &info
+}
+
+func (p *partialInfo) save(partPath string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(partPath+partialInfoSuffix, data, 0o644)
+}
+
+// removePartial discards an unfinished download along with its record
+func removePartial(partPath string) {
+	os.Remove(partPath)
+	os.Remove(partPath + partialInfoSuffix)
+}
+
+// downloadStream fetches url into partPath over one connection, continuing
+// from whatever partPath already holds if its record says it is the same
+// object. When storage rejects the URL, reauth is called for a fresh one
+// and the download picks up at the same offset.
+func (c *Client) downloadStream(ctx context.Context, url, partPath, id string, reauth func() (string, error)) error {
+	info := loadPartialInfo(partPath)
+	if info == nil || info.ID != id {
+		// A part file nothing vouches for is started over, never extended
+		if stat, err := os.Stat(partPath); err == nil {
+			c.untrack(stat.Size())
+		}
+		if err := os.WriteFile(partPath, nil, 0o644); err != nil {
+			return err
+		}
+		info = &partialInfo{ID: id, Size: -1}
+		if err := info.save(partPath); err != nil {
+			return err
+		}
+	}
+
+	failures := 0
+	refreshed := false
+	for {
+		progressed, err := c.downloadPart(ctx, url, partPath, info)
+		if err == nil {
+			return nil
+		}
+		if progressed {
+			failures = 0
+			refreshed = false
+		}
+
+		// A second rejection straight after a refresh is a real denial
+		if errors.Is(err, errLinkExpired) && reauth != nil && !refreshed {
+			fresh, authErr := reauth()
+			if authErr != nil {
+				return fmt.Errorf("re-authentication failed: %w", authErr)
+			}
+			url = fresh
+			refreshed = true
+			continue
+		}
+
+		var netErr *interruptedError
+		if !errors.As(err, &netErr) {
+			return err
+		}
+
+		failures++
+		if failures >= maxDownloadAttempts {
+			return err
+		}
+		if err := sleep(ctx, time.Duration(failures)*time.Second); err != nil {
+			return err
+		}
+	}
+}
+
+// interruptedError marks a transfer that broke off and can be continued
+type interruptedError struct {
+	err error
+}
+
+func (e *interruptedError) Error() string { return "download interrupted: " + e.err.Error() }
+func (e *interruptedError) Unwrap() error { return e.err }
+
+// downloadPart appends the rest of url to partPath. It reports whether any
+// new bytes reached the disk so callers can tell a stall from slow progress.
+// With If-Range a server whose object changed sends all of it, replacing
+// what partPath held.
+func (c *Client) downloadPart(ctx context.Context, url, partPath string, info *partialInfo) (bool, error) {
+	out, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return false, err
+	}
+	defer out.Close()
+
+	stat, err := out.Stat()
+	if err != nil {
+		return false, err
+	}
+	offset := stat.Size()
+	if info.Size >= 0 && offset == info.Size {
+		return false, nil // An earlier attempt got everything
+	}
+
+	// restart empties partPath so the next attempt fetches from byte 0
+	restart := func() error {
+		c.untrack(offset)
+		*info = partialInfo{ID: info.ID, Size: -1}
+		if err := out.Truncate(0); err != nil {
+			return err
+		}
+		if err := info.save(partPath); err != nil {
+			return err
+		}
+		return &interruptedError{errObjectChanged}
+	}
+	if info.Size >= 0 && offset > info.Size {
+		return false, restart()
+	}
+
+	ctx, guard := c.guard(ctx)
+	defer guard.stop()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if info.ETag != "" {
+			req.Header.Set("If-Range", info.ETag)
+		}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return false, &interruptedError{err}
+	}
+	defer resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		var start, end, total int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil || start != offset {
+			return false, fmt.Errorf("download failed: unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		if (info.Size >= 0 && total != info.Size) || (info.ETag != "" && etag != "" && etag != info.ETag) {
+			return false, restart()
+		}
+		if info.Size < 0 {
+			info.Size, info.ETag = total, etag
+			if err := info.save(partPath); err != nil {
+				return false, err
+			}
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The object is shorter than what we hold, so it is not the same one
+		return false, restart()
+	case resp.StatusCode == http.StatusOK:
+		// Range ignored or the object changed, start over
+		c.untrack(offset)
+		offset = 0
+		if err := out.Truncate(0); err != nil {
+			return false, err
+		}
+		*info = partialInfo{ID: info.ID, Size: resp.ContentLength, ETag: etag}
+		if err := info.save(partPath); err != nil {
+			return false, err
+		}
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		return false, errLinkExpired
+	default:
+		return false, newError("download", resp)
+	}
+
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		return false, err
+	}
+
+	n, err := io.Copy(out, c.track(guard.reader(resp.Body)))
+	if err != nil {
+		return n > 0, &interruptedError{guard.err(err)}
+	}
+	if resp.ContentLength >= 0 && n < resp.ContentLength {
+		return n > 0, &interruptedError{io.ErrUnexpectedEOF}
+	}
+	return true, nil
+}
+
+// StreamDownload writes the object to w instead of a file, for pipelines.
+// Dropped connections and expired URLs are handled like Download by asking
+// for the remaining bytes with a Range request. Bytes already written cannot
+// be taken back, so a checksum mismatch is only reported once w has them all.
+func (c *Client) StreamDownload(d DownloadRequest, w io.Writer) error {
+	return c.StreamDownloadContext(context.Background(), d, w)
+}
+
+// StreamDownloadContext is StreamDownload with a context for cancellation
+func (c *Client) StreamDownloadContext(ctx context.Context, d DownloadRequest, w io.Writer) error {
+	hash := sha256.New()
+	out := io.MultiWriter(w, hash)
+
+	url := d.URL
+	object := objectVersion{size: -1}
+	var written int64
+	failures := 0
+	refreshed := false
+
+	for {
+		n, err := c.streamFrom(ctx, url, &object, written, out)
+		written += n
+		if err == nil {
+			break
+		}
+		if n > 0 {
+			failures = 0
+			refreshed = false
+		}
+
+		if errors.Is(err, errLinkExpired) && d.Reauth != nil && !refreshed {
+			fresh, authErr := d.Reauth()
+			if authErr != nil {
+				return fmt.Errorf("re-authentication failed: %w", authErr)
+			}
+			url = fresh
+			refreshed = true
+			continue
+		}
+
+		var netErr *interruptedError
+		if !errors.As(err, &netErr) {
+			return err
+		}
+
+		failures++
+		if failures >= maxDownloadAttempts {
+			return err
+		}
+		if err := sleep(ctx, time.Duration(failures)*time.Second); err != nil {
+			return err
+		}
+	}
+
+	if d.SHA256 != "" {
+		if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, d.SHA256) {
+			return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, d.SHA256, sum)
+		}
+	}
+	return nil
+}
+
+// streamFrom writes the object to w from offset on. The first response
+// records which object is being streamed; later ones send If-Range and
+// must be that same object, since w already holds its first bytes.
+func (c *Client) streamFrom(ctx context.Context, url string, object *objectVersion, offset int64, w io.Writer) (int64, error) {
+	ctx, guard := c.guard(ctx)
+	defer guard.stop()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if object.etag != "" {
+			req.Header.Set("If-Range", object.etag)
+		}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, &interruptedError{err}
+	}
+	defer resp.Body.Close()
+
+	body := guard.reader(resp.Body)
+	etag := resp.Header.Get("ETag")
+	want := resp.ContentLength
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		var start, end, total int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil || start != offset {
+			return 0, fmt.Errorf("download failed: unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		if (object.size >= 0 && total != object.size) || (object.etag != "" && etag != "" && etag != object.etag) {
+			return 0, errObjectReplaced
+		}
+	case resp.StatusCode == http.StatusOK:
+		if offset == 0 {
+			*object = objectVersion{size: resp.ContentLength, etag: etag}
+			break
+		}
+		// Range ignored, or If-Range saw another version. Only the same
+		// object may have its first offset bytes skipped.
+		if etag != object.etag || (object.size >= 0 && resp.ContentLength >= 0 && resp.ContentLength != object.size) {
+			return 0, errObjectReplaced
+		}
+		if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+			return 0, &interruptedError{guard.err(err)}
+		}
+		if want >= 0 {
+			want -= offset
+		}
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusUnauthorized:
+		return 0, errLinkExpired
+	default:
+		return 0, newError("download", resp)
+	}
+
+	n, err := io.Copy(w, c.track(body))
+	if err != nil {
+		return n, &interruptedError{guard.err(err)}
+	}
+	if want >= 0 && n < want {
+		return n, &interruptedError{io.ErrUnexpectedEOF}
+	}
+	return n, nil
+}
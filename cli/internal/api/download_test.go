@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+// flakyObject serves content, dropping the first response halfway through.
+// Later responses ignore Range and come with the ETag from next.
+type flakyObject struct {
+	content []byte
+	next    func() string
+
+	mu       sync.Mutex
+	requests int
+	ifRange  []string
+}
+
+func (f *flakyObject) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests++
+	first := f.requests == 1
+	f.ifRange = append(f.ifRange, r.Header.Get("If-Range"))
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(f.content)))
+	if first {
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		w.Write(f.content[:len(f.content)/2])
+		panic(http.ErrAbortHandler)
+	}
+	w.Header().Set("ETag", f.next())
+	w.WriteHeader(http.StatusOK)
+	w.Write(f.content)
+}
+
+func TestStreamDownloadSkipsOnlyTheSameObject(t *testing.T) {
+	content := randomBytes(t, 64<<10)
+
+	for name, etag := range map[string]string{"same": `"v1"`, "replaced": `"v2"`} {
+		obj := &flakyObject{content: content, next: func() string { return etag }}
+		srv := httptest.NewServer(obj)
+
+		var out bytes.Buffer
+		c := New(&config.Config{APIBase: srv.URL})
+		err := c.StreamDownloadContext(context.Background(), DownloadRequest{URL: srv.URL, SHA256: sha256Hex(content)}, &out)
+		srv.Close()
+
+		if len(obj.ifRange) != 2 || obj.ifRange[1] != `"v1"` {
+			t.Errorf("%s: If-Range sent %q, want the first ETag on the reconnect", name, obj.ifRange)
+		}
+		switch name {
+		case "same":
+			if err != nil || !bytes.Equal(out.Bytes(), content) {
+				t.Errorf("same: %v, wrote %d of %d bytes", err, out.Len(), len(content))
+			}
+		case "replaced":
+			if !errors.Is(err, errObjectReplaced) {
+				t.Errorf("replaced: %v, want errObjectReplaced", err)
+			}
+			if out.Len() != len(content)/2 {
+				t.Errorf("replaced: wrote %d bytes, want only the first response's %d", out.Len(), len(content)/2)
+			}
+		}
+	}
+}
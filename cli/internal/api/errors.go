@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Sentinels for errors.Is. An *Error matches one when its status or server
+// code says so, e.g. errors.Is(err, api.ErrNotFound).
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	ErrUnauthorized  = errors.New("unauthorized")
+	ErrExpired       = errors.New("expired")
+)
+
+// Error is a non-success response from the API or from storage
+type Error struct {
+	Op        string // What failed, e.g. "upload request"
+	Status    int    // HTTP status code
+	Code      string // Server error code, e.g. "quota_exceeded", if it sent one
+	Message   string // Server message, or the raw body when it was not JSON
+	RequestID string // For support requests, if the server sent one
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s failed: %s", e.Op, msg)
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == http.StatusNotFound || e.Code == "not_found"
+	case ErrQuotaExceeded:
+		return e.Status == http.StatusRequestEntityTooLarge || e.Status == http.StatusInsufficientStorage ||
+			e.Code == "quota_exceeded"
+	case ErrUnauthorized:
+		return e.Status == http.StatusUnauthorized || e.Status == http.StatusForbidden ||
+			e.Code == "unauthorized" || e.Code == "invalid_api_key" || e.Code == "invalid_subscription"
+	case ErrExpired:
+		return e.Status == http.StatusGone || e.Code == "expired"
+	}
+	return false
+}
+
+// newError builds an *Error from a failed response, reading its body. The
+// API answers {"error": {"code", "message", "request_id"}}; older endpoints
+// send {"error": "message"} and storage sends XML or plain text.
+func newError(op string, resp *http.Response) *Error {
+	e := &Error{
+		Op:        op,
+		Status:    resp.StatusCode,
+		RequestID: resp.Header.Get("X-Request-ID"),
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	var nested struct {
+		Error struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	var flat struct {
+		Error     string `json:"error"`
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+
+	switch {
+	case json.Unmarshal(body, &nested) == nil && (nested.Error.Code != "" || nested.Error.Message != ""):
+		e.Code, e.Message = nested.Error.Code, nested.Error.Message
+		if nested.Error.RequestID != "" {
+			e.RequestID = nested.Error.RequestID
+		}
+	case json.Unmarshal(body, &flat) == nil && (flat.Error != "" || flat.Code != "" || flat.Message != ""):
+		e.Code, e.Message = flat.Code, flat.Message
+		if e.Message == "" {
+			e.Message = flat.Error
+		}
+		if flat.RequestID != "" {
+			e.RequestID = flat.RequestID
+		}
+	default:
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return e
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// APIKey is one of the account's API keys. The key itself is only ever
+// sent back by CreateKey.
+type APIKey struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes,omitempty"`    // Empty for a full-access key
+	DeviceID   string   `json:"device_id,omitempty"` // Empty for keys made by CreateKey
+	DeviceName string   `json:"device_name,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"` // Empty if never used
+	Current    bool     `json:"current,omitempty"`      // The key making the request
+
+	Key string `json:"api_key,omitempty"`
+}
+
+// Device is a machine that logged in to the account
+type Device struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at,omitempty"`
+	Current    bool   `json:"current,omitempty"` // The device making the request
+}
+
+// CreateKeyRequest names a new key and limits what it may do
+type CreateKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+// CreateKey issues a new API key for the logged-in account, e.g. for a CI
+// pipeline. It is not bound to this device.
+func (c *Client) CreateKey(create CreateKeyRequest) (*APIKey, error) {
+	return c.CreateKeyContext(context.Background(), create)
+}
+
+// CreateKeyContext is CreateKey with a context for cancellation
+func (c *Client) CreateKeyContext(ctx context.Context, create CreateKeyRequest) (*APIKey, error) {
+	payload, _ := json.Marshal(create)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/account/keys", bytes.NewBuffer(payload))
+	c.attachAuth(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, newError("key creation", resp)
+	}
+
+	var out APIKey
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListKeys returns every API key on the account, marking the one this
+// client is using
+func (c *Client) ListKeys() ([]APIKey, error) {
+	return c.ListKeysContext(context.Background())
+}
+
+// ListKeysContext is ListKeys with a context for cancellation
+func (c *Client) ListKeysContext(ctx context.Context) ([]APIKey, error) {
+	var keys []APIKey
+	if err := c.getJSON(ctx, "/v1/account/keys", "key list", &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeKey stops a key working at once, wherever it is used
+func (c *Client) RevokeKey(id string) error {
+	return c.RevokeKeyContext(context.Background(), id)
+}
+
+// RevokeKeyContext is RevokeKey with a context for cancellation
+func (c *Client) RevokeKeyContext(ctx context.Context, id string) error {
+	return c.deleteResource(ctx, "/v1/account/keys/"+url.PathEscape(id), "key revoke")
+}
+
+// RenameKey changes the label of a key, the key itself stays valid
+func (c *Client) RenameKey(id, name string) (*APIKey, error) {
+	return c.RenameKeyContext(context.Background(), id, name)
+}
+
+// RenameKeyContext is RenameKey with a context for cancellation
+func (c *Client) RenameKeyContext(ctx context.Context, id, name string) (*APIKey, error) {
+	payload, _ := json.Marshal(map[string]string{"name": name})
+
+	req, _ := http.NewRequestWithContext(ctx, "PATCH", c.baseURL+"/v1/account/keys/"+url.PathEscape(id), bytes.NewBuffer(payload))
+	c.attachAuth(req)
+	markIdempotent(req) // Setting the same name twice is harmless
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newError("key rename", resp)
+	}
+
+	var out APIKey
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListDevices returns every device bound to the account, marking this one
+func (c *Client) ListDevices() ([]Device, error) {
+	return c.ListDevicesContext(context.Background())
+}
+
+// ListDevicesContext is ListDevices with a context for cancellation
+func (c *Client) ListDevicesContext(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	if err := c.getJSON(ctx, "/v1/account/devices", "device list", &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RevokeDevice signs a device out, revoking every key bound to it
+func (c *Client) RevokeDevice(id string) error {
+	return c.RevokeDeviceContext(context.Background(), id)
+}
+
+// RevokeDeviceContext is RevokeDevice with a context for cancellation
+func (c *Client) RevokeDeviceContext(ctx context.Context, id string) error {
+	return c.deleteResource(ctx, "/v1/account/devices/"+url.PathEscape(id), "device revoke")
+}
+
+// getJSON fetches an authenticated resource into out
+func (c *Client) getJSON(ctx context.Context, path, op string, out interface{}) error {
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	c.attachAuth(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newError(op, resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// deleteResource deletes an authenticated resource
+func (c *Client) deleteResource(ctx context.Context, path, op string) error {
+	req, _ := http.NewRequestWithContext(ctx, "DELETE", c.baseURL+path, nil)
+	c.attachAuth(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return newError(op, resp)
+	}
+	return nil
+}
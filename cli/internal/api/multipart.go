@@ -0,0 +1,282 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Files at or above this size ask the server for a multipart session
+	MultipartThreshold = 100 * 1024 * 1024
+
+	DefaultUploadWorkers = 4
+)
+
+// UploadPart is one presigned part URL handed out by /v1/upload/request
+type UploadPart struct {
+	Number int    `json:"part_number"`
+	URL    string `json:"url"`
+}
+
+// CompletedPart is sent back to /v1/upload/complete once a part is stored
+type CompletedPart struct {
+	Number int    `json:"part_number"`
+	ETag   string `json:"etag"`
+}
+
+// IsMultipart reports whether the server negotiated a multipart session
+func (u *UploadInitResponse) IsMultipart() bool {
+	return u.UploadID != "" && len(u.Parts) > 0
+}
+
+func (u *UploadInitResponse) partSize(size int64) int64 {
+	if u.PartSize > 0 {
+		return u.PartSize
+	}
+	n := int64(len(u.Parts))
+	return (size + n - 1) / n
+}
+
+// PartRange is where part `number` lies in an upload of size bytes
+func (u *UploadInitResponse) PartRange(number int, size int64) (offset, length int64) {
+	partSize := u.partSize(size)
+	offset = int64(number-1) * partSize
+	length = partSize
+	if offset+length > size {
+		length = size - offset
+	}
+	return offset, length
+}
+
+// SentBytes is how many of the upload's size bytes the done parts cover
+func (u *UploadInitResponse) SentBytes(size int64, done []CompletedPart) int64 {
+	var sent int64
+	for _, p := range done {
+		if _, n := u.PartRange(p.Number, size); n > 0 {
+			sent += n
+		}
+	}
+	return sent
+}
+
+// UploadMultipart uploads every part of localPath to its presigned URL using
+// up to `workers` concurrent requests. A failed part stops new parts from
+// being started and the first error is returned.
+func (c *Client) UploadMultipart(init *UploadInitResponse, localPath string, workers int) ([]CompletedPart, error) {
+	return c.UploadMultipartContext(context.Background(), init, localPath, workers)
+}
+
+// UploadMultipartContext is UploadMultipart with a context for cancellation
+func (c *Client) UploadMultipartContext(ctx context.Context, init *UploadInitResponse, localPath string, workers int) ([]CompletedPart, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ResumeMultipartContext(ctx, init, f, stat.Size(), workers, nil, nil)
+}
+
+// ResumeMultipart is UploadMultipart over any source of `size` bytes, for a
+// session that may already have some parts stored. Parts listed in `done`
+// are skipped, and onPart (if set) is called from the worker goroutines as
+// each new part is confirmed.
+func (c *Client) ResumeMultipart(init *UploadInitResponse, src io.ReaderAt, size int64, workers int,
+	done []CompletedPart, onPart func(CompletedPart)) ([]CompletedPart, error) {
+	return c.ResumeMultipartContext(context.Background(), init, src, size, workers, done, onPart)
+}
+
+// ResumeMultipartContext is ResumeMultipart with a context for cancellation
+func (c *Client) ResumeMultipartContext(ctx context.Context, init *UploadInitResponse, src io.ReaderAt, size int64, workers int,
+	done []CompletedPart, onPart func(CompletedPart)) ([]CompletedPart, error) {
+
+	skip := make(map[int]bool, len(done))
+	for _, p := range done {
+		skip[p.Number] = true
+	}
+
+	var pending []UploadPart
+	for _, part := range init.Parts {
+		if !skip[part.Number] {
+			pending = append(pending, part)
+		}
+	}
+
+	if workers <= 0 {
+		workers = DefaultUploadWorkers
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	jobs := make(chan UploadPart)
+	quit := make(chan struct{})
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		completed = append([]CompletedPart(nil), done...)
+		firstErr  error
+		once      sync.Once
+	)
+
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(quit)
+		})
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for part := range jobs {
+				offset, length := init.PartRange(part.Number, size)
+				if length < 0 {
+					fail(fmt.Errorf("part %d is beyond end of file", part.Number))
+					return
+				}
+
+				etag, err := c.uploadPart(ctx, part.URL, io.NewSectionReader(src, offset, length), length)
+				if err != nil {
+					fail(fmt.Errorf("part %d: %w", part.Number, err))
+					return
+				}
+
+				cp := CompletedPart{Number: part.Number, ETag: etag}
+				if onPart != nil {
+					onPart(cp)
+				}
+
+				mu.Lock()
+				completed = append(completed, cp)
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, part := range pending {
+		select {
+		case jobs <- part:
+		case <-quit:
+			break dispatch
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	sort.Slice(completed, func(i, j int) bool {
+		return completed[i].Number < completed[j].Number
+	})
+	return completed, nil
+}
+
+func (c *Client) uploadPart(ctx context.Context, url string, body io.Reader, size int64) (string, error) {
+	ctx, guard := c.guard(ctx)
+	defer guard.stop()
+
+	wrap := func(r io.Reader) io.Reader { return guard.reader(c.track(r)) }
+	req, _ := http.NewRequestWithContext(ctx, "PUT", url, wrap(body))
+	c.rewindable(req, body, wrap)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.ContentLength = size
+
+	resp, err := c.do(req)
+	if err != nil {
+		return "", guard.err(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return "", newError("upload", resp)
+	}
+
+	etag := strings.Trim(resp.Header.Get("ETag"), `"`)
+	if etag == "" {
+		return "", fmt.Errorf("storage did not return an ETag")
+	}
+	return etag, nil
+}
+
+// ResumeUpload re-opens an unfinished upload and returns freshly presigned
+// URLs for it, since the ones handed out originally may have expired
+func (c *Client) ResumeUpload(fileID, uploadID string) (*UploadInitResponse, error) {
+	return c.ResumeUploadContext(context.Background(), fileID, uploadID)
+}
+
+// ResumeUploadContext is ResumeUpload with a context for cancellation
+func (c *Client) ResumeUploadContext(ctx context.Context, fileID, uploadID string) (*UploadInitResponse, error) {
+	payload := fmt.Sprintf(`{"file_id":"%s","upload_id":"%s"}`, fileID, uploadID)
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/upload/resume", bytes.NewBuffer([]byte(payload)))
+	c.attachAuth(req)
+	markIdempotent(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newError("upload resume", resp)
+	}
+
+	var out UploadInitResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CompleteUpload tells the server to assemble the uploaded parts
+func (c *Client) CompleteUpload(fileID, uploadID string, parts []CompletedPart) error {
+	return c.CompleteUploadContext(context.Background(), fileID, uploadID, parts)
+}
+
+// CompleteUploadContext is CompleteUpload with a context for cancellation
+func (c *Client) CompleteUploadContext(ctx context.Context, fileID, uploadID string, parts []CompletedPart) error {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"file_id":   fileID,
+		"upload_id": uploadID,
+		"parts":     parts,
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/upload/complete", bytes.NewBuffer(payload))
+	c.attachAuth(req)
+	markIdempotent(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return newError("upload complete", resp)
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides how often and how patiently a failed request is sent
+// again. Requests that may have changed something on the server are only
+// repeated when it certainly did not act on them: the connection never
+// opened, or it answered 429 or 503.
+type RetryPolicy struct {
+	MaxRetries int           // Attempts after the first one, 0 disables retrying
+	BaseDelay  time.Duration // Wait before the first retry, doubled for each one after
+	MaxDelay   time.Duration // Longest backoff between two attempts
+	MaxElapsed time.Duration // No retry starts after this long, 0 for no limit
+}
+
+// DefaultRetryPolicy is used by New
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 4,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   30 * time.Second,
+	MaxElapsed: 2 * time.Minute,
+}
+
+// SetRetryPolicy replaces the client's retry policy
+func (c *Client) SetRetryPolicy(p RetryPolicy) {
+	c.retry = p
+}
+
+// SetLog makes the client report every retry to w. Pass nil to stop.
+func (c *Client) SetLog(w io.Writer) {
+	c.log = w
+}
+
+// backoff is the jittered wait before retry number n (0-based)
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.MaxDelay
+	if n < 30 {
+		if exp := p.BaseDelay << n; exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Anywhere in [d/2, d], so clients that failed together spread out
+	return d/2 + time.Duration(mrand.Int63n(int64(d/2)+1))
+}
+
+// do sends req, retrying transient failures as c.retry allows. Like
+// http.Client.Do it returns the last response as-is when retries run out.
+// A body is only resent if req.GetBody can produce it again.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	for n := 0; ; n++ {
+		resp, err := c.http.Do(req)
+
+		retry, wait, reason := c.retryable(req, resp, err)
+		if !retry || n >= c.retry.MaxRetries {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, err
+		}
+		if wait == 0 {
+			wait = c.retry.backoff(n)
+		}
+		if c.retry.MaxElapsed > 0 && time.Since(start)+wait > c.retry.MaxElapsed {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+		if c.log != nil {
+			fmt.Fprintf(c.log, "%s %s: %s, retry %d/%d in %s\n",
+				req.Method, redactURL(req), reason, n+1, c.retry.MaxRetries, wait.Round(time.Millisecond))
+		}
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// retryable classifies one attempt. wait is the server's Retry-After, or 0
+// to use the policy's backoff.
+func (c *Client) retryable(req *http.Request, resp *http.Response, err error) (retry bool, wait time.Duration, reason string) {
+	if req.Context().Err() != nil {
+		return false, 0, ""
+	}
+
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true, 0, "connect failed" // Nothing reached the server
+		}
+		var certErr *tls.CertificateVerificationError
+		if errors.As(err, &certErr) {
+			return false, 0, ""
+		}
+		// Not the *url.Error itself, its text has the presigned URL in it
+		cause := err
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			cause = urlErr.Err
+		}
+		return idempotent(req), 0, "connection error: " + cause.Error()
+	}
+
+	reason = resp.Status
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		// Both mean the request was turned away before being handled
+		return true, retryAfter(resp.Header.Get("Retry-After")), reason
+	case http.StatusRequestTimeout, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent(req), 0, reason
+	}
+	return false, 0, ""
+}
+
+// idempotent reports whether sending req twice does no more harm than once
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// markIdempotent tags a POST that is safe to repeat. The key stays the same
+// across retries so a server that deduplicates can do so.
+func markIdempotent(req *http.Request) {
+	key := make([]byte, 16)
+	rand.Read(key)
+	req.Header.Set("Idempotency-Key", hex.EncodeToString(key))
+}
+
+// retryAfter parses a Retry-After header, in seconds or as an HTTP date
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// redactURL drops the query, presigned URLs carry their signature there
+func redactURL(req *http.Request) string {
+	u := *req.URL
+	u.RawQuery = ""
+	return u.String()
+}
+
+// rewindable lets a retry resend body from the start when it can seek,
+// taking back the progress the failed attempt already reported. Pipes are
+// *os.File too but cannot seek, so they are sent once.
+func (c *Client) rewindable(req *http.Request, body io.Reader, wrap func(io.Reader) io.Reader) {
+	rs, ok := body.(io.ReadSeeker)
+	if !ok {
+		return
+	}
+	if _, err := rs.Seek(0, io.SeekCurrent); err != nil {
+		return
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		sent, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		c.untrack(sent)
+		return io.NopCloser(wrap(rs)), nil
+	}
+}
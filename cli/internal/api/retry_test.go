@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+// scripted answers each request with the next status in its script, 200
+// once the script runs out, and keeps the bodies it was sent
+type scripted struct {
+	statuses   []int
+	retryAfter string
+
+	mu     sync.Mutex
+	bodies [][]byte
+}
+
+func (s *scripted) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mu.Lock()
+	n := len(s.bodies)
+	s.bodies = append(s.bodies, body)
+	s.mu.Unlock()
+
+	if n < len(s.statuses) {
+		if s.retryAfter != "" {
+			w.Header().Set("Retry-After", s.retryAfter)
+		}
+		w.WriteHeader(s.statuses[n])
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// newRetryClient talks to s with retries that wait a few milliseconds
+func newRetryClient(t *testing.T, s *scripted) (*Client, string) {
+	t.Helper()
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	c := New(&config.Config{APIBase: srv.URL})
+	c.SetRetryPolicy(RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+	return c, srv.URL
+}
+
+func TestRetryHonoursRetryAfter(t *testing.T) {
+	s := &scripted{statuses: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}, retryAfter: "1"}
+	c, url := newRetryClient(t, s)
+
+	// Turned away before being handled, so even a plain POST goes again
+	req, _ := http.NewRequest("POST", url, bytes.NewReader([]byte("payload")))
+	start := time.Now()
+	resp, err := c.do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || len(s.bodies) != 3 {
+		t.Fatalf("got %s after %d requests, want 200 after 3", resp.Status, len(s.bodies))
+	}
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Errorf("retried after %s, want the two 1s Retry-After waits", elapsed)
+	}
+}
+
+func TestRetrySkipsNonIdempotentPost(t *testing.T) {
+	s := &scripted{statuses: []int{http.StatusBadGateway}}
+	c, url := newRetryClient(t, s)
+
+	req, _ := http.NewRequest("POST", url, bytes.NewReader([]byte("payload")))
+	resp, err := c.do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || len(s.bodies) != 1 {
+		t.Errorf("got %s after %d requests, want the 502 after 1", resp.Status, len(s.bodies))
+	}
+
+	// The same POST with an Idempotency-Key may be repeated
+	s = &scripted{statuses: []int{http.StatusBadGateway}}
+	c, url = newRetryClient(t, s)
+	req, _ = http.NewRequest("POST", url, bytes.NewReader([]byte("payload")))
+	markIdempotent(req)
+	if resp, err = c.do(req); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(s.bodies) != 2 {
+		t.Errorf("marked POST: got %s after %d requests, want 200 after 2", resp.Status, len(s.bodies))
+	}
+}
+
+func TestRetryResendsSeekableBodyInFull(t *testing.T) {
+	s := &scripted{statuses: []int{http.StatusInternalServerError, http.StatusServiceUnavailable}}
+	c, url := newRetryClient(t, s)
+
+	content := randomBytes(t, 256<<10)
+	body := io.NewSectionReader(bytes.NewReader(content), 0, int64(len(content)))
+	if err := c.UploadReaderContext(context.Background(), url, body, int64(len(content))); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.bodies) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(s.bodies))
+	}
+	for i, got := range s.bodies {
+		if !bytes.Equal(got, content) {
+			t.Errorf("attempt %d sent %d bytes, want all %d", i+1, len(got), len(content))
+		}
+	}
+}
+
+func TestRetrySendsUnseekableBodyOnce(t *testing.T) {
+	s := &scripted{statuses: []int{http.StatusServiceUnavailable}}
+	c, url := newRetryClient(t, s)
+
+	content := randomBytes(t, 64<<10)
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.Close()
+	go func() {
+		pw.Write(content)
+		pw.Close()
+	}()
+
+	err = c.UploadReaderContext(context.Background(), url, pr, int64(len(content)))
+	if err == nil {
+		t.Fatal("upload succeeded, want the 503")
+	}
+	if len(s.bodies) != 1 || !bytes.Equal(s.bodies[0], content) {
+		t.Errorf("sent %d requests, want the pipe's bytes exactly once", len(s.bodies))
+	}
+}
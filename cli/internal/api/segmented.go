@@ -0,0 +1,266 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// Objects smaller than this per connection are not worth splitting
+	minSegmentSize = 8 * 1024 * 1024
+
+	// Suffix of the preallocated file segments write into. Until every
+	// segment is done it has holes, so it is never resumed: only once
+	// complete does it become the ".part" file.
+	segmentsSuffix = ".segments"
+)
+
+// downloadSegmented fetches url into partPath over up to `connections`
+// concurrent Range requests, each writing its slice of a preallocated
+// file next to it. It falls back to a single downloadStream when the storage
+// server does not honour ranges, the object is small, or an earlier partial
+// file exists.
+func (c *Client) downloadSegmented(ctx context.Context, url, partPath, id string, connections int, reauth func() (string, error)) error {
+	if _, err := os.Stat(partPath); err == nil {
+		return c.downloadStream(ctx, url, partPath, id, reauth)
+	}
+
+	object, ranged, err := c.probeRange(ctx, url)
+	if errors.Is(err, errLinkExpired) && reauth != nil {
+		if url, err = reauth(); err != nil {
+			return fmt.Errorf("re-authentication failed: %w", err)
+		}
+		object, ranged, err = c.probeRange(ctx, url)
+	}
+	if err != nil {
+		return err
+	}
+	total := object.size
+	if !ranged || total < 2*minSegmentSize {
+		return c.downloadStream(ctx, url, partPath, id, reauth)
+	}
+
+	if max := int(total / minSegmentSize); connections > max {
+		connections = max
+	}
+
+	segPath := partPath + segmentsSuffix
+	out, err := os.OpenFile(segPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := out.Truncate(total); err != nil {
+		out.Close()
+		os.Remove(segPath)
+		return err
+	}
+
+	// One segment giving up stops the others, the file is discarded anyway
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	links := &sharedURL{url: url, reauth: reauth}
+	segSize := (total + int64(connections) - 1) / int64(connections)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, connections)
+
+	for start := int64(0); start < total; start += segSize {
+		end := start + segSize - 1
+		if end >= total {
+			end = total - 1
+		}
+
+		wg.Add(1)
+		go func(start, end int64) {
+			defer wg.Done()
+			if err := c.fetchSegment(ctx, links, out, object, start, end); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(start, end)
+	}
+
+	wg.Wait()
+	close(errs)
+	closeErr := out.Close()
+
+	if err := <-errs; err != nil {
+		os.Remove(segPath)
+		return err
+	}
+	if closeErr != nil {
+		os.Remove(segPath)
+		return closeErr
+	}
+
+	// Renamed before its record is written: a crash in between leaves a
+	// ".part" nothing vouches for, which is started over
+	if err := os.Rename(segPath, partPath); err != nil {
+		return err
+	}
+	info := partialInfo{ID: id, Size: total, ETag: object.etag}
+	return info.save(partPath)
+}
+
+// objectVersion pins every segment to the object probeRange saw
+type objectVersion struct {
+	size int64
+	etag string
+}
+
+// probeRange asks for the first byte to learn the object's size and ETag
+// and whether the server answers Range requests with 206
+func (c *Client) probeRange(ctx context.Context, url string) (objectVersion, bool, error) {
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return objectVersion{}, false, err
+	}
+	defer resp.Body.Close()
+
+	etag := resp.Header.Get("ETag")
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var start, end, total int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &total); err != nil {
+			return objectVersion{size: resp.ContentLength}, false, nil
+		}
+		return objectVersion{size: total, etag: etag}, true, nil
+	case http.StatusOK:
+		return objectVersion{size: resp.ContentLength, etag: etag}, false, nil
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return objectVersion{}, false, errLinkExpired
+	default:
+		return objectVersion{}, false, newError("download", resp)
+	}
+}
+
+// fetchSegment writes bytes [start, end] of the object into out, retrying
+// from the last written offset when the connection drops
+func (c *Client) fetchSegment(ctx context.Context, links *sharedURL, out *os.File, object objectVersion, start, end int64) error {
+	failures := 0
+	for start <= end {
+		url := links.get()
+
+		n, err := c.fetchRange(ctx, url, out, object, start, end)
+		start += n
+		if err == nil {
+			continue
+		}
+		if n > 0 {
+			failures = 0
+		}
+
+		failures++
+		if failures >= maxDownloadAttempts {
+			return err
+		}
+
+		if errors.Is(err, errLinkExpired) {
+			if refreshErr := links.refresh(url); refreshErr != nil {
+				return refreshErr
+			}
+			continue
+		}
+
+		var netErr *interruptedError
+		if !errors.As(err, &netErr) {
+			return err
+		}
+		if err := sleep(ctx, time.Duration(failures)*time.Second); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchRange writes bytes [start, end] of object into out. With If-Range a
+// server whose object changed answers 200, which fails the whole download
+// rather than mixing two versions in one file.
+func (c *Client) fetchRange(ctx context.Context, url string, out *os.File, object objectVersion, start, end int64) (int64, error) {
+	ctx, guard := c.guard(ctx)
+	defer guard.stop()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if object.etag != "" {
+		req.Header.Set("If-Range", object.etag)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, &interruptedError{err}
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		var first, last, total int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &first, &last, &total); err != nil ||
+			first != start || last > end {
+			return 0, fmt.Errorf("download failed: unexpected Content-Range %q", resp.Header.Get("Content-Range"))
+		}
+		etag := resp.Header.Get("ETag")
+		if total != object.size || (object.etag != "" && etag != "" && etag != object.etag) {
+			return 0, errObjectReplaced
+		}
+	case http.StatusOK:
+		return 0, errObjectReplaced
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return 0, errLinkExpired
+	default:
+		return 0, newError("download", resp)
+	}
+
+	want := end - start + 1
+	n, err := io.Copy(io.NewOffsetWriter(out, start), c.track(guard.reader(io.LimitReader(resp.Body, want))))
+	if err != nil {
+		return n, &interruptedError{guard.err(err)}
+	}
+	if n < want {
+		return n, &interruptedError{io.ErrUnexpectedEOF}
+	}
+	return n, nil
+}
+
+// sharedURL lets every segment see a refreshed presigned URL while making
+// sure only one of them calls reauth per expiry
+type sharedURL struct {
+	mu     sync.Mutex
+	url    string
+	reauth func() (string, error)
+}
+
+func (s *sharedURL) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.url
+}
+
+func (s *sharedURL) refresh(stale string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.url != stale {
+		return nil // Another segment already refreshed it
+	}
+	if s.reauth == nil {
+		return errLinkExpired
+	}
+
+	fresh, err := s.reauth()
+	if err != nil {
+		return fmt.Errorf("re-authentication failed: %w", err)
+	}
+	s.url = fresh
+	return nil
+}
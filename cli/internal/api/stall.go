@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrStalled is returned when a transfer body stops moving for longer than
+// Timeouts.Stall. It is retried like a dropped connection.
+var ErrStalled = errors.New("transfer stalled")
+
+// Timeouts bound the phases of a request. Zero leaves a phase unlimited.
+// There is no overall deadline, large transfers take as long as they take.
+type Timeouts struct {
+	Connect time.Duration // TCP connect plus TLS handshake
+	Header  time.Duration // Waiting for the response once the request is sent
+	Stall   time.Duration // No body bytes moving in either direction
+}
+
+// DefaultTimeouts are used by New
+var DefaultTimeouts = Timeouts{
+	Connect: 30 * time.Second,
+	Header:  10 * time.Minute,
+	Stall:   2 * time.Minute,
+}
+
+// SetTimeouts replaces the client's timeouts. Requests already in flight
+// keep the old ones.
+func (c *Client) SetTimeouts(t Timeouts) {
+	c.timeouts = t
+	c.http = &http.Client{Transport: newTransport(t)}
+}
+
+func newTransport(t Timeouts) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   t.Connect,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   t.Connect,
+		ExpectContinueTimeout: t.Header,
+		ResponseHeaderTimeout: t.Header,
+	}
+}
+
+// stallGuard cancels a request whose body has gone quiet. The clock only
+// runs while bytes are expected: it starts with the first body read and
+// stops when the body is drained, so slow server responses are left to
+// the header timeout.
+type stallGuard struct {
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+
+	mu    sync.Mutex
+	timer *time.Timer
+}
+
+// guard derives the context for one request from ctx. Wrap the transfer
+// body with reader, map failures through err and call stop when done.
+func (c *Client) guard(ctx context.Context) (context.Context, *stallGuard) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return ctx, &stallGuard{ctx: ctx, cancel: cancel, timeout: c.timeouts.Stall}
+}
+
+func (g *stallGuard) reader(r io.Reader) io.Reader {
+	if g.timeout <= 0 {
+		return r
+	}
+	return &stallReader{r: r, g: g}
+}
+
+// err reports ErrStalled for a failure caused by the guard firing
+func (g *stallGuard) err(err error) error {
+	if errors.Is(context.Cause(g.ctx), ErrStalled) {
+		return ErrStalled
+	}
+	return err
+}
+
+func (g *stallGuard) stop() {
+	g.pause()
+	g.cancel(nil)
+}
+
+func (g *stallGuard) kick() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.timer == nil {
+		g.timer = time.AfterFunc(g.timeout, func() { g.cancel(ErrStalled) })
+		return
+	}
+	g.timer.Reset(g.timeout)
+}
+
+func (g *stallGuard) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+}
+
+type stallReader struct {
+	r io.Reader
+	g *stallGuard
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	s.g.kick()
+	n, err := s.r.Read(p)
+	if err != nil {
+		s.g.pause()
+	} else {
+		s.g.kick()
+	}
+	return n, err
+}
+
+// sleep waits d or until ctx is done, whichever comes first
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	// Part size used when the total length is not known up front
+	DefaultStreamPartSize = 16 * 1024 * 1024
+
+	// Part URLs requested per round trip while streaming
+	partURLBatch = 16
+)
+
+// RequestPartURLs presigns URLs for more parts of a streaming upload
+func (c *Client) RequestPartURLs(fileID, uploadID string, numbers []int) ([]UploadPart, error) {
+	return c.RequestPartURLsContext(context.Background(), fileID, uploadID, numbers)
+}
+
+// RequestPartURLsContext is RequestPartURLs with a context for cancellation
+func (c *Client) RequestPartURLsContext(ctx context.Context, fileID, uploadID string, numbers []int) ([]UploadPart, error) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"file_id":      fileID,
+		"upload_id":    uploadID,
+		"part_numbers": numbers,
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/v1/upload/parts", bytes.NewBuffer(payload))
+	c.attachAuth(req)
+	markIdempotent(req)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newError("part request", resp)
+	}
+
+	var out struct {
+		Parts []UploadPart `json:"parts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return out.Parts, nil
+}
+
+// UploadStream uploads r, whose length is not known in advance, as a
+// sequence of parts. Each part is buffered in memory and up to `workers`
+// parts are in flight at once, so peak memory is about (workers+1) parts.
+// It returns the completed parts and the total number of bytes sent.
+func (c *Client) UploadStream(init *UploadInitResponse, r io.Reader, workers int) ([]CompletedPart, int64, error) {
+	return c.UploadStreamContext(context.Background(), init, r, workers)
+}
+
+// UploadStreamContext is UploadStream with a context for cancellation
+func (c *Client) UploadStreamContext(ctx context.Context, init *UploadInitResponse, r io.Reader, workers int) ([]CompletedPart, int64, error) {
+	partSize := init.PartSize
+	if partSize <= 0 {
+		partSize = DefaultStreamPartSize
+	}
+	if workers <= 0 {
+		workers = DefaultUploadWorkers
+	}
+
+	urls := make(map[int]string, len(init.Parts))
+	for _, p := range init.Parts {
+		urls[p.Number] = p.URL
+	}
+
+	type job struct {
+		number int
+		url    string
+		data   []byte
+	}
+
+	jobs := make(chan job)
+	free := make(chan []byte, workers+1)
+	for i := 0; i < workers+1; i++ {
+		free <- make([]byte, partSize)
+	}
+	quit := make(chan struct{})
+
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		completed []CompletedPart
+		firstErr  error
+		once      sync.Once
+	)
+
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			close(quit)
+		})
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				etag, err := c.uploadPart(ctx, j.url, bytes.NewReader(j.data), int64(len(j.data)))
+				free <- j.data[:cap(j.data)]
+				if err != nil {
+					fail(fmt.Errorf("part %d: %w", j.number, err))
+					return
+				}
+
+				mu.Lock()
+				completed = append(completed, CompletedPart{Number: j.number, ETag: etag})
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var total int64
+
+read:
+	for number := 1; ; number++ {
+		var buf []byte
+		select {
+		case buf = <-free:
+		case <-quit:
+			break read
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break read
+		}
+
+		n, err := io.ReadFull(r, buf)
+		last := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !last {
+			fail(err)
+			break
+		}
+
+		// An exact multiple of partSize ends with an empty read, which
+		// only needs uploading if the whole stream was empty
+		if n == 0 && number > 1 {
+			break
+		}
+
+		url, ok := urls[number]
+		if !ok {
+			numbers := make([]int, partURLBatch)
+			for i := range numbers {
+				numbers[i] = number + i
+			}
+			parts, err := c.RequestPartURLsContext(ctx, init.FileID, init.UploadID, numbers)
+			if err != nil {
+				fail(err)
+				break
+			}
+			for _, p := range parts {
+				urls[p.Number] = p.URL
+			}
+			if url, ok = urls[number]; !ok {
+				fail(fmt.Errorf("server did not presign part %d", number))
+				break
+			}
+		}
+
+		select {
+		case jobs <- job{number: number, url: url, data: buf[:n]}:
+			total += int64(n)
+		case <-quit:
+			break read
+		case <-ctx.Done():
+			fail(ctx.Err())
+			break read
+		}
+
+		if last {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, 0, firstErr
+	}
+
+	sort.Slice(completed, func(i, j int) bool {
+		return completed[i].Number < completed[j].Number
+	})
+	return completed, total, nil
+}
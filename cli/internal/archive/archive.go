@@ -0,0 +1,361 @@
+package archive
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	Ext     = ".tar"
+	ZstdExt = ".tar.zst"
+)
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Name picks an upload filename for an archive of paths
+func Name(paths []string, compress bool) string {
+	name := "bucket-archive"
+	if len(paths) == 1 {
+		if base := filepath.Base(filepath.Clean(paths[0])); base != "." && base != string(filepath.Separator) {
+			name = base
+		}
+	}
+	if compress {
+		return name + ZstdExt
+	}
+	return name + Ext
+}
+
+// IsArchive reports whether name looks like something Write produced
+func IsArchive(name string) bool {
+	return strings.HasSuffix(name, Ext) || strings.HasSuffix(name, ZstdExt)
+}
+
+// Write streams a tar of paths to w, optionally zstd-compressed. Each path
+// is stored under its base name; directories are walked recursively and
+// file modes, modification times and symlinks are kept as-is.
+func Write(w io.Writer, paths []string, compress bool) error {
+	if err := CheckRoots(paths); err != nil {
+		return err
+	}
+
+	out := w
+	var enc *zstd.Encoder
+	if compress {
+		var err error
+		if enc, err = zstd.NewWriter(w); err != nil {
+			return err
+		}
+		out = enc
+	}
+
+	tw := tar.NewWriter(out)
+	for _, root := range paths {
+		if err := addTree(tw, filepath.Clean(root)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if enc != nil {
+		return enc.Close()
+	}
+	return nil
+}
+
+// CheckRoots refuses paths that Write would store under the same name,
+// which would interleave into a single tree
+func CheckRoots(paths []string) error {
+	roots := make(map[string]string, len(paths))
+	for _, root := range paths {
+		base := filepath.Base(filepath.Clean(root))
+		if other, ok := roots[base]; ok {
+			return fmt.Errorf("%s and %s would both be archived as %s", other, root, base)
+		}
+		roots[base] = root
+	}
+	return nil
+}
+
+func addTree(tw *tar.Writer, root string) error {
+	parent := filepath.Dir(root)
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := os.Lstat(path)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		rel, err := filepath.Rel(parent, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// Extract unpacks a tar (plain or zstd) from r into dest. Entries that would
+// land outside dest, through absolute paths, ".." components, or links and
+// directories pointing elsewhere, are refused.
+func Extract(r io.Reader, dest string) error {
+	dest, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	if dest, err = filepath.EvalSymlinks(dest); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if head, _ := br.Peek(len(zstdMagic)); bytes.Equal(head, zstdMagic) {
+		dec, err := zstd.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer dec.Close()
+		src = dec
+	}
+
+	tr := tar.NewReader(src)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := safeJoin(dest, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if err := checkParents(dest, target); err != nil {
+			return err
+		}
+
+		mode := fs.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, mode|0o700); err != nil {
+				return err
+			}
+
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := writeFile(target, tr, mode); err != nil {
+				return err
+			}
+			os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			if err := checkLink(dest, target, hdr.Linkname); err != nil {
+				return fmt.Errorf("refusing symlink %s -> %s: %w", hdr.Name, hdr.Linkname, err)
+			}
+			os.Remove(target)
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+
+		case tar.TypeLink:
+			source, err := safeJoin(dest, hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			if err := checkParents(dest, source); err != nil {
+				return err
+			}
+			// Only files this archive could have put there, never a link
+			// or directory that would hand out something else
+			if info, err := os.Lstat(source); err != nil || !info.Mode().IsRegular() {
+				return fmt.Errorf("refusing hard link %s -> %s: not a regular file in %s", hdr.Name, hdr.Linkname, dest)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			os.Remove(target)
+			if err := os.Link(source, target); err != nil {
+				return err
+			}
+
+		default:
+			// Devices, fifos and the like are never written by Write
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader, mode fs.FileMode) error {
+	// Never follow whatever might already sit at path
+	os.Remove(path)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// safeJoin resolves an archive entry name below dest
+func safeJoin(dest, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" {
+		return "", fmt.Errorf("refusing absolute path in archive: %s", name)
+	}
+
+	target := filepath.Join(dest, filepath.FromSlash(name))
+	if !within(dest, target) {
+		return "", fmt.Errorf("refusing path outside destination: %s", name)
+	}
+	return target, nil
+}
+
+// checkParents makes sure no directory between dest and target is a symlink
+// that an earlier entry planted to redirect writes elsewhere
+func checkParents(dest, target string) error {
+	rel, err := filepath.Rel(dest, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return err
+	}
+
+	path := dest
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			resolved, err := filepath.EvalSymlinks(path)
+			if err != nil || !within(dest, resolved) {
+				return fmt.Errorf("refusing to write through symlink %s", path)
+			}
+		}
+	}
+	return nil
+}
+
+// checkLink makes sure a symlink at target pointing to linkname resolves
+// inside dest, wherever the links already extracted lead. Leading ".."
+// components are taken from the directory the link really sits in, and
+// any ".." after a name is refused since it would undo a link lexically
+// that the filesystem follows.
+func checkLink(dest, target, linkname string) error {
+	base, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if filepath.IsAbs(linkname) {
+		base = string(filepath.Separator)
+		if vol := filepath.VolumeName(linkname); vol != "" {
+			base = vol + base
+			linkname = linkname[len(vol):]
+		}
+	}
+
+	path, down, exists := base, false, true
+	for _, part := range strings.FieldsFunc(linkname, isSeparator) {
+		switch {
+		case part == ".":
+			continue
+		case part == "..":
+			if down {
+				return fmt.Errorf(`".." after a name`)
+			}
+			path = filepath.Dir(path)
+			continue
+		}
+
+		down = true
+		path = filepath.Join(path, part)
+		if !exists {
+			continue
+		}
+		// Links already in place lead wherever they really lead
+		info, err := os.Lstat(path)
+		if err != nil {
+			exists = false
+			continue
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if path, err = filepath.EvalSymlinks(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !within(dest, path) {
+		return fmt.Errorf("points outside %s", dest)
+	}
+	return nil
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == filepath.Separator
+}
+
+func within(dest, path string) bool {
+	rel, err := filepath.Rel(dest, filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
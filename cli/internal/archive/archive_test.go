@@ -0,0 +1,180 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// entry is one tar header for a hand-built archive
+type entry struct {
+	name, link string
+	kind       byte
+	body       string
+}
+
+func file(name, body string) entry   { return entry{name: name, kind: tar.TypeReg, body: body} }
+func dir(name string) entry          { return entry{name: name, kind: tar.TypeDir} }
+func symlink(name, to string) entry  { return entry{name: name, link: to, kind: tar.TypeSymlink} }
+func hardlink(name, to string) entry { return entry{name: name, link: to, kind: tar.TypeLink} }
+
+func build(t *testing.T, entries ...entry) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Linkname: e.link, Typeflag: e.kind, Mode: 0o644, Size: int64(len(e.body))}
+		if e.kind == tar.TypeDir {
+			hdr.Mode = 0o755
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// sandbox returns a destination inside a parent directory that holds a
+// file escaping entries would try to reach or overwrite. The destination
+// already has a link "up" to that parent.
+func sandbox(t *testing.T) (dest, outside string) {
+	t.Helper()
+	parent := t.TempDir()
+	dest = filepath.Join(parent, "dest")
+	if err := os.Mkdir(dest, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(dest, "up")); err != nil {
+		t.Fatal(err)
+	}
+	outside = filepath.Join(parent, "foo")
+	if err := os.WriteFile(outside, []byte("untouched"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dest, outside
+}
+
+func TestExtractRefusesEscapes(t *testing.T) {
+	cases := map[string][]entry{
+		"dot-dot entry":             {file("../foo", "pwned")},
+		"dot-dot inside a name":     {file("a/../../foo", "pwned")},
+		"absolute name":             {file("/tmp/foo", "pwned")},
+		"symlink out":               {symlink("l", "../foo")},
+		"absolute symlink":          {symlink("l", "/etc/passwd")},
+		"symlink chain":             {symlink("x", "."), symlink("x/y", "../foo")},
+		"dot-dot after a link":      {symlink("a", "."), symlink("z", "a/../foo")},
+		"dot-dot after a later dir": {symlink("z", "later/../../foo"), symlink("later", ".")},
+		"write through a new link":  {symlink("l", "."), file("l/../../foo", "pwned")},
+		"hardlink out":              {hardlink("h", "../foo")},
+		"hardlink to absolute":      {hardlink("h", "/etc/passwd")},
+		"write through a link":      {file("up/foo", "pwned")},
+		"symlink through a link":    {symlink("l", "up/foo")},
+		"hardlink through a link":   {hardlink("h", "up/foo")},
+		"hardlink to a symlink":     {file("f", "x"), symlink("s", "f"), hardlink("h", "s")},
+		"hardlink to a directory":   {dir("d/"), hardlink("h", "d")},
+	}
+	for name, entries := range cases {
+		dest, outside := sandbox(t)
+		if err := Extract(build(t, entries...), dest); err == nil {
+			t.Errorf("%s: extracted without error", name)
+		}
+		if data, _ := os.ReadFile(outside); string(data) != "untouched" {
+			t.Errorf("%s: file outside dest was changed", name)
+		}
+		if _, err := os.Lstat(filepath.Join(filepath.Dir(dest), "h")); err == nil {
+			t.Errorf("%s: created an entry outside dest", name)
+		}
+	}
+}
+
+func TestExtractKeepsLinksInside(t *testing.T) {
+	dest, _ := sandbox(t)
+	err := Extract(build(t,
+		dir("d/"),
+		file("d/f", "hello"),
+		symlink("d/same", "f"),
+		symlink("d/sibling", "../d/f"),
+		symlink("loop", "."),
+		symlink("d/via", "../loop/d/f"),
+		hardlink("h", "d/f"),
+	), dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"d/same", "d/sibling", "d/via", "h"} {
+		if data, err := os.ReadFile(filepath.Join(dest, name)); err != nil || string(data) != "hello" {
+			t.Errorf("%s reads %q, %v", name, data, err)
+		}
+	}
+}
+
+func TestWriteExtractRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	root := filepath.Join(src, "tree")
+	for path, body := range map[string]string{"a.txt": "a", "sub/b.txt": "b", "sub/deeper/c.txt": "c"} {
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../a.txt", filepath.Join(root, "sub", "link")); err != nil {
+		t.Fatal(err)
+	}
+	single := filepath.Join(src, "single.txt")
+	if err := os.WriteFile(single, []byte("s"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, []string{root, single}, false); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+	if err := Extract(&buf, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, body := range map[string]string{
+		"tree/a.txt": "a", "tree/sub/b.txt": "b", "tree/sub/deeper/c.txt": "c", "tree/sub/link": "a", "single.txt": "s",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dest, path)); err != nil || string(data) != body {
+			t.Errorf("%s reads %q, %v, want %q", path, data, err, body)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dest, "single.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("single.txt mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestWriteRefusesDuplicateRoots(t *testing.T) {
+	src := t.TempDir()
+	for _, d := range []string{"a/data", "b/data"} {
+		if err := os.MkdirAll(filepath.Join(src, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := Write(&buf, []string{filepath.Join(src, "a", "data"), filepath.Join(src, "b", "data") + "/"}, false)
+	if err == nil {
+		t.Fatal("a/data and b/data archived together")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("wrote %d bytes before refusing", buf.Len())
+	}
+}
@@ -1,22 +1,58 @@
 package config
 
 import (
-	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 )
 
+// DefaultAPIBase is used by profiles that do not set api_base
+const DefaultAPIBase = "https://api.bucketlabs.org"
+
 type Config struct {
 	APIBase    string `json:"api_base"`
-	APIKey     string `json:"api_key"`     // Stores RAW UUID (no prefix/suffix)
-	DeviceID   string `json:"device_id"`   // Used for device binding
+	APIKey     string `json:"api_key,omitempty"`     // Stores RAW UUID (no prefix/suffix)
+	APIKeyRef  string `json:"api_key_ref,omitempty"` // Where APIKey is kept instead, see CredentialStore
+	DeviceID   string `json:"device_id"`             // Used for device binding
 	DeviceName string `json:"device_name"`
 	Tier       string `json:"tier"`
 	UsedBytes  int64  `json:"used_bytes"`
 	Quota      int64  `json:"quota"`
+
+	UploadWorkers int `json:"upload_workers,omitempty"` // Concurrent parts for multipart push
+
+	APIBaseOverride string            `json:"-"` // Set by a project file, BUCKET_API_BASE or --api-base, never saved
+	Profile         string            `json:"-"` // Name this config was loaded as
+	Origins         map[string]Origin `json:"-"` // Where each setting came from, see Load
+	Deprecated      []string          `json:"-"` // Warnings about outdated settings Load still honoured
+
+	storedKey string // APIKey as found behind APIKeyRef, so Save can skip rewriting it
+}
+
+// BaseURL is the API server to talk to
+func (c *Config) BaseURL() string {
+	if c.APIBaseOverride != "" {
+		return c.APIBaseOverride
+	}
+	return c.APIBase
+}
+
+// pathOverride replaces the default config location, see SetPath
+var pathOverride string
+
+// SetPath makes Load, Save and Dir use path instead of BUCKET_CONFIG or the
+// default location
+func SetPath(path string) {
+	pathOverride = path
 }
 
 func configPath() string {
+	if pathOverride != "" {
+		return pathOverride
+	}
+	if path := os.Getenv(EnvConfig); path != "" {
+		return path
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "bucket_config.json"
@@ -28,40 +64,95 @@ func Path() string {
 	return configPath()
 }
 
-func Load() (*Config, error) {
-	path := configPath()
+// Dir is the directory holding config.json and other local client state
+func Dir() string {
+	return filepath.Dir(configPath())
+}
+
+// IdentitiesPath is the file holding X25519 identities made by `bucket keygen`
+func IdentitiesPath() string {
+	return filepath.Join(Dir(), "identities")
+}
 
-	data, err := os.ReadFile(path)
+// Load returns the active profile with the environment and any project
+// file applied on top, see applyLayers. The profile is the one chosen with
+// SetProfile, BUCKET_PROFILE or the project file, or else the file's
+// default. A config file from before profiles is migrated.
+func Load() (*Config, error) {
+	f, err := LoadFile()
 	if err != nil {
-		// default config
-		return &Config{
-			APIBase: "https://api.bucketlabs.org",
-			APIKey:  "",
-		}, nil
+		return nil, err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+	name, origin := f.activeProfile()
+	cfg, ok := f.Profiles[name]
+	if !ok {
+		if name != f.DefaultProfile {
+			return nil, fmt.Errorf("%w %q (from %s), create it with: bucket profile add %s", ErrNoProfile, name, origin, name)
+		}
+		cfg = &Config{}
 	}
+	cfg.Profile = name
+	cfg.Origins = map[string]Origin{"profile": origin}
 
-	if cfg.APIBase == "" {
-		cfg.APIBase = "https://api.bucketlabs.org"
+	if os.Getenv(EnvAPIKey) == "" {
+		if err := loadKey(cfg); err != nil {
+			return nil, err
+		}
+		if cfg.APIKey != "" && cfg.APIKeyRef == "" {
+			// A plaintext key from before credential stores. Move it now if
+			// that needs no passphrase, otherwise the next login does.
+			if s, err := f.storeFor(); err == nil && s != nil && s.Name() != fileStoreName {
+				_ = f.put(name, cfg)
+			}
+		}
 	}
 
-	return &cfg, nil
+	if err := applyLayers(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
 }
 
+// Save stores cfg as the profile it was loaded from, leaving the others
 func Save(cfg *Config) error {
-	path := configPath()
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+	f, err := LoadFile()
+	if err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
+	name := cfg.Profile
+	if name == "" {
+		name = f.Active()
+	}
+	return f.put(name, cfg)
+}
+
+// put saves cfg as profile name, with its key moved to the credential
+// store. Settings that came from the environment or a project file keep
+// their saved values.
+func (f *File) put(name string, cfg *Config) error {
+	prev := f.Profiles[name]
+	if prev == nil {
+		prev = &Config{}
+	}
+
+	saved := *cfg
+	saved.Origins = nil
+	if cfg.Overridden("upload_workers") {
+		saved.UploadWorkers = prev.UploadWorkers
+	}
+	if cfg.Overridden("api_key") {
+		saved.APIKey, saved.APIKeyRef = prev.APIKey, prev.APIKeyRef
+	} else if err := f.storeKey(name, &saved, prev); err != nil {
+		return err
+	}
+	f.Profiles[name] = &saved
+	if err := f.Save(); err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0o600)
-}
\ No newline at end of file
+	cfg.APIKeyRef = saved.APIKeyRef
+	cfg.storedKey = saved.storedKey
+	return nil
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// CredentialStore keeps secrets such as API keys out of config.json, which
+// then only records a reference of the form "<store name>:<account>"
+type CredentialStore interface {
+	Name() string
+	Get(account string) (string, error) // ErrCredentialNotFound if there is none
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+var (
+	ErrCredentialNotFound = errors.New("credential not found")
+	ErrStoreUnavailable   = errors.New("credential store unavailable")
+)
+
+// Values for File.CredentialStore
+const (
+	StoreAuto    = ""        // OS keyring, else the encrypted file if a passphrase can be asked for
+	StoreKeyring = "keyring" // OS keyring only
+	StoreFile    = "file"    // Encrypted file only
+	StorePlain   = "plain"   // Keep keys in config.json as before
+)
+
+var (
+	storeOverride CredentialStore
+
+	systemOnce sync.Once
+	system     CredentialStore
+)
+
+// SetCredentialStore makes Load and Save use s for every key, e.g. a
+// MemoryStore when running headless. Pass nil to go back to the default.
+func SetCredentialStore(s CredentialStore) {
+	storeOverride = s
+}
+
+// systemKeyring is the OS keyring, or nil where none is reachable
+func systemKeyring() CredentialStore {
+	systemOnce.Do(func() { system = systemStore() })
+	return system
+}
+
+// storeFor picks where Save puts new keys, nil for config.json itself
+func (f *File) storeFor() (CredentialStore, error) {
+	if storeOverride != nil {
+		return storeOverride, nil
+	}
+
+	switch f.CredentialStore {
+	case StorePlain:
+		return nil, nil
+	case StoreKeyring:
+		if s := systemKeyring(); s != nil {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%w: no OS keyring found", ErrStoreUnavailable)
+	case StoreFile:
+		return fileStore(), nil
+	case StoreAuto:
+		if s := systemKeyring(); s != nil {
+			return s, nil
+		}
+		if passphrase != nil {
+			return fileStore(), nil
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown credential_store %q", f.CredentialStore)
+}
+
+// resolve finds the store a reference points at
+func resolve(ref string) (CredentialStore, string, error) {
+	name, account, ok := strings.Cut(ref, ":")
+	if !ok {
+		return nil, "", fmt.Errorf("malformed credential reference %q", ref)
+	}
+	for _, s := range []CredentialStore{storeOverride, systemKeyring()} {
+		if s != nil && s.Name() == name {
+			return s, account, nil
+		}
+	}
+	if name == fileStoreName {
+		return fileStore(), account, nil
+	}
+	return nil, "", fmt.Errorf("%w: %s", ErrStoreUnavailable, name)
+}
+
+// loadKey fills in cfg.APIKey from the store its reference points at. A
+// reference to a credential that is gone reads as logged out.
+func loadKey(cfg *Config) error {
+	if cfg.APIKeyRef == "" {
+		return nil
+	}
+	s, account, err := resolve(cfg.APIKeyRef)
+	if err != nil {
+		return err
+	}
+	key, err := s.Get(account)
+	if errors.Is(err, ErrCredentialNotFound) {
+		cfg.APIKeyRef = ""
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading API key from %s: %w", s.Name(), err)
+	}
+	cfg.APIKey = key
+	cfg.storedKey = key
+	return nil
+}
+
+// storeKey moves cfg.APIKey into the credential store, leaving only its
+// reference, and drops the credential prev referenced if it is replaced.
+// An unchanged key is left where it is.
+func (f *File) storeKey(profile string, cfg, prev *Config) error {
+	oldRef := ""
+	if prev != nil {
+		oldRef = prev.APIKeyRef
+	}
+
+	switch {
+	case cfg.APIKey == "":
+		cfg.APIKeyRef = ""
+	case cfg.APIKeyRef != "" && cfg.APIKey == cfg.storedKey:
+		cfg.APIKey = ""
+		return nil
+	default:
+		s, err := f.storeFor()
+		if err != nil {
+			return err
+		}
+		cfg.APIKeyRef = ""
+		if s != nil {
+			account := "profile/" + profile
+			if err := s.Set(account, cfg.APIKey); err != nil {
+				return fmt.Errorf("saving API key to %s: %w", s.Name(), err)
+			}
+			cfg.storedKey = cfg.APIKey
+			cfg.APIKey = ""
+			cfg.APIKeyRef = s.Name() + ":" + account
+		}
+	}
+
+	if oldRef != "" && oldRef != cfg.APIKeyRef {
+		deleteRef(oldRef)
+	}
+	return nil
+}
+
+// deleteRef removes a stored credential, best effort
+func deleteRef(ref string) {
+	if s, account, err := resolve(ref); err == nil {
+		_ = s.Delete(account)
+	}
+}
+
+// StoreSecret keeps a secret other than an API key, e.g. the encryption
+// key of a resumable push, wherever API keys go and returns a reference
+// to it. With keys kept in config.json it returns "" and the caller has
+// to keep the secret itself.
+func StoreSecret(account, secret string) (string, error) {
+	f, err := LoadFile()
+	if err != nil {
+		return "", err
+	}
+	s, err := f.storeFor()
+	if err != nil || s == nil {
+		return "", err
+	}
+	if err := s.Set(account, secret); err != nil {
+		return "", fmt.Errorf("saving secret to %s: %w", s.Name(), err)
+	}
+	return s.Name() + ":" + account, nil
+}
+
+// LoadSecret reads back a secret saved by StoreSecret
+func LoadSecret(ref string) (string, error) {
+	s, account, err := resolve(ref)
+	if err != nil {
+		return "", err
+	}
+	return s.Get(account)
+}
+
+// DeleteSecret drops a secret saved by StoreSecret, best effort
+func DeleteSecret(ref string) {
+	deleteRef(ref)
+}
+
+// KeyLocation describes where cfg's API key is kept, for messages
+func KeyLocation(cfg *Config) string {
+	if cfg.APIKeyRef == "" {
+		return Path()
+	}
+	name, _, _ := strings.Cut(cfg.APIKeyRef, ":")
+	if name == fileStoreName {
+		return CredentialsPath()
+	}
+	return name
+}
+
+// MemoryStore keeps credentials for the life of the process, for tests and
+// other headless runs
+type MemoryStore struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{secrets: map[string]string{}}
+}
+
+func (m *MemoryStore) Name() string { return "memory" }
+
+func (m *MemoryStore) Get(account string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secret, ok := m.secrets[account]
+	if !ok {
+		return "", ErrCredentialNotFound
+	}
+	return secret, nil
+}
+
+func (m *MemoryStore) Set(account, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets[account] = secret
+	return nil
+}
+
+func (m *MemoryStore) Delete(account string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.secrets, account)
+	return nil
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolate points the package at a fresh config directory with keys kept
+// in a MemoryStore, and no environment or project file in the way
+func isolate(t *testing.T) *MemoryStore {
+	t.Helper()
+	for _, name := range []string{EnvAPIKey, EnvAPIBase, EnvLegacyAPIBase, EnvProfile, EnvUploadWorkers, EnvConfig} {
+		t.Setenv(name, "")
+	}
+
+	mem := NewMemoryStore()
+	SetPath(filepath.Join(t.TempDir(), "config.json"))
+	SetCredentialStore(mem)
+	project, projectPath, projectErr, projectDone = nil, "", nil, true
+	t.Cleanup(func() {
+		SetPath("")
+		SetCredentialStore(nil)
+		SetPassphrase(nil)
+		projectDone = false
+	})
+	return mem
+}
+
+func readConfig(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSaveKeepsKeyInStore(t *testing.T) {
+	mem := isolate(t)
+
+	if err := Save(&Config{APIKey: "secret-1", Profile: DefaultProfile}); err != nil {
+		t.Fatal(err)
+	}
+	if data := readConfig(t); strings.Contains(data, "secret-1") {
+		t.Fatalf("key written to config.json:\n%s", data)
+	}
+	if got, _ := mem.Get("profile/default"); got != "secret-1" {
+		t.Fatalf("stored key = %q, want secret-1", got)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "secret-1" || cfg.APIKeyRef != "memory:profile/default" {
+		t.Fatalf("loaded key %q from %q", cfg.APIKey, cfg.APIKeyRef)
+	}
+
+	cfg.APIKey = "secret-2"
+	if err := Save(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "secret-2" {
+		t.Fatalf("loaded key %q after replacing it, want secret-2", cfg.APIKey)
+	}
+}
+
+func TestLoadMigratesPlaintextKey(t *testing.T) {
+	mem := isolate(t)
+
+	// Single-account layout from before profiles and credential stores
+	legacy := `{"api_base":"https://example.test","api_key":"old-key","device_id":"d1"}`
+	if err := os.WriteFile(Path(), []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "old-key" || cfg.APIBase != "https://example.test" || cfg.DeviceID != "d1" {
+		t.Fatalf("migrated config = %+v", cfg)
+	}
+	if data := readConfig(t); strings.Contains(data, "old-key") || !strings.Contains(data, `"profiles"`) {
+		t.Fatalf("config.json not migrated:\n%s", data)
+	}
+	if got, _ := mem.Get("profile/default"); got != "old-key" {
+		t.Fatalf("stored key = %q, want old-key", got)
+	}
+}
+
+func TestRemoveDeletesStoredKey(t *testing.T) {
+	mem := isolate(t)
+
+	if err := Save(&Config{APIKey: "k", Profile: "work"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mem.Get("profile/work"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("stored key still there after Remove: %v", err)
+	}
+}
+
+func TestMissingCredentialReadsAsLoggedOut(t *testing.T) {
+	mem := isolate(t)
+
+	if err := Save(&Config{APIKey: "k", Profile: DefaultProfile}); err != nil {
+		t.Fatal(err)
+	}
+	mem.Delete("profile/default")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIKey != "" || cfg.APIKeyRef != "" {
+		t.Fatalf("loaded key %q from %q, want none", cfg.APIKey, cfg.APIKeyRef)
+	}
+}
+
+func TestEncryptedFileRoundTrip(t *testing.T) {
+	isolate(t)
+	SetPassphrase(func(bool) (string, error) { return "correct horse", nil })
+
+	if err := (&encryptedFile{}).Set("profile/default", "file-secret"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(CredentialsPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "file-secret") {
+		t.Fatal("secret stored in the clear")
+	}
+
+	// A new instance has to derive the key again from the passphrase
+	got, err := (&encryptedFile{}).Get("profile/default")
+	if err != nil || got != "file-secret" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+	if _, err := (&encryptedFile{}).Get("profile/other"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("Get of a missing account: %v", err)
+	}
+
+	SetPassphrase(func(bool) (string, error) { return "wrong", nil })
+	if _, err := (&encryptedFile{}).Get("profile/default"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("Get with a wrong passphrase: %v", err)
+	}
+
+	// Deleting needs no passphrase at all
+	SetPassphrase(nil)
+	if err := (&encryptedFile{}).Delete("profile/default"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&encryptedFile{}).Get("profile/default"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("Get after Delete: %v", err)
+	}
+}
+
+func TestEncryptedFileBindsAccounts(t *testing.T) {
+	isolate(t)
+	SetPassphrase(func(bool) (string, error) { return "pw", nil })
+
+	e := &encryptedFile{}
+	if err := e.Set("a", "secret-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("b", "secret-b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// An entry moved to another account must not open there
+	cf, err := e.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf.Entries["a"], cf.Entries["b"] = cf.Entries["b"], cf.Entries["a"]
+	if err := e.write(cf); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := e.Get("a"); err == nil {
+		t.Fatalf("swapped entry opened as %q", got)
+	}
+}
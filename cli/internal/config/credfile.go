@@ -0,0 +1,230 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Encrypted credentials file, the fallback where there is no OS keyring.
+// Every entry is sealed with AES-256-GCM under a key derived from the
+// passphrase with PBKDF2-HMAC-SHA256; the account name is the associated
+// data so entries cannot be swapped around.
+const (
+	fileStoreName = "file"
+
+	kdfIterations = 600_000
+	checkAccount  = "\x00check" // Sealed marker that tells a wrong passphrase apart
+)
+
+var ErrWrongPassphrase = errors.New("wrong passphrase for the credentials file")
+
+// passphrase asks for the credentials file passphrase, see SetPassphrase
+var passphrase func(create bool) (string, error)
+
+// SetPassphrase tells the encrypted file store how to ask for its
+// passphrase. create is true when the file is about to be made, so the
+// caller can ask twice. Without it the file store is never picked by
+// default and reports ErrStoreUnavailable when it is needed.
+func SetPassphrase(fn func(create bool) (string, error)) {
+	passphrase = fn
+}
+
+// CredentialsPath is the encrypted credentials file
+func CredentialsPath() string {
+	return filepath.Join(Dir(), "credentials.enc")
+}
+
+type credentialsFile struct {
+	Version    int               `json:"version"`
+	Iterations int               `json:"iterations"`
+	Salt       []byte            `json:"salt"`
+	Entries    map[string][]byte `json:"entries"` // nonce || ciphertext
+}
+
+type encryptedFile struct {
+	mu  sync.Mutex
+	key []byte // Derived at most once per run
+}
+
+var (
+	fileOnce   sync.Once
+	fileShared *encryptedFile
+)
+
+func fileStore() CredentialStore {
+	fileOnce.Do(func() { fileShared = &encryptedFile{} })
+	return fileShared
+}
+
+func (e *encryptedFile) Name() string { return fileStoreName }
+
+func (e *encryptedFile) Get(account string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cf, err := e.read()
+	if err != nil {
+		return "", err
+	}
+	sealed, ok := cf.Entries[account]
+	if cf.Salt == nil || !ok {
+		return "", ErrCredentialNotFound
+	}
+
+	gcm, err := e.unlock(cf, false)
+	if err != nil {
+		return "", err
+	}
+	secret, err := open(gcm, sealed, account)
+	if err != nil {
+		return "", fmt.Errorf("credential %s is damaged: %w", account, err)
+	}
+	return string(secret), nil
+}
+
+func (e *encryptedFile) Set(account, secret string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cf, err := e.read()
+	if err != nil {
+		return err
+	}
+	create := cf.Salt == nil
+	if create {
+		cf.Version = 1
+		cf.Iterations = kdfIterations
+		cf.Salt = make([]byte, 16)
+		if _, err := rand.Read(cf.Salt); err != nil {
+			return err
+		}
+	}
+
+	gcm, err := e.unlock(cf, create)
+	if err != nil {
+		return err
+	}
+	if create {
+		if cf.Entries[checkAccount], err = seal(gcm, nil, checkAccount); err != nil {
+			return err
+		}
+	}
+	if cf.Entries[account], err = seal(gcm, []byte(secret), account); err != nil {
+		return err
+	}
+	return e.write(cf)
+}
+
+// Delete needs no passphrase, entries are dropped without opening them
+func (e *encryptedFile) Delete(account string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cf, err := e.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := cf.Entries[account]; !ok {
+		return nil
+	}
+	delete(cf.Entries, account)
+	return e.write(cf)
+}
+
+func (e *encryptedFile) read() (*credentialsFile, error) {
+	cf := &credentialsFile{Entries: map[string][]byte{}}
+	data, err := os.ReadFile(CredentialsPath())
+	if os.IsNotExist(err) {
+		return cf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cf); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", CredentialsPath(), err)
+	}
+	if cf.Entries == nil {
+		cf.Entries = map[string][]byte{}
+	}
+	return cf, nil
+}
+
+// write replaces the file in one rename so a crash never leaves half of it
+func (e *encryptedFile) write(cf *credentialsFile) error {
+	path := CredentialsPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// unlock derives the file key, asking for the passphrase the first time
+func (e *encryptedFile) unlock(cf *credentialsFile, create bool) (cipher.AEAD, error) {
+	if e.key == nil {
+		if passphrase == nil {
+			return nil, fmt.Errorf("%w: the credentials file needs a passphrase", ErrStoreUnavailable)
+		}
+		pass, err := passphrase(create)
+		if err != nil {
+			return nil, err
+		}
+		key, err := pbkdf2.Key(sha256.New, pass, cf.Salt, cf.Iterations, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		if check, ok := cf.Entries[checkAccount]; ok {
+			gcm, err := newGCM(key)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := open(gcm, check, checkAccount); err != nil {
+				return nil, ErrWrongPassphrase
+			}
+		}
+		e.key = key
+	}
+	return newGCM(e.key)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func seal(gcm cipher.AEAD, plain []byte, account string) ([]byte, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, []byte(account)), nil
+}
+
+func open(gcm cipher.AEAD, sealed []byte, account string) ([]byte, error) {
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("entry too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, []byte(account))
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// macOS Keychain through /usr/bin/security, as generic passwords in the
+// user's login keychain
+const keyringService = "bucket"
+
+const errSecItemNotFound = 44 // Exit status of security for a missing item
+
+type keychain struct{}
+
+func systemStore() CredentialStore {
+	if _, err := exec.LookPath("security"); err != nil {
+		return nil
+	}
+	return keychain{}
+}
+
+func (keychain) Name() string { return "keychain" }
+
+func (k keychain) Get(account string) (string, error) {
+	out, err := k.run("", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (k keychain) Set(account, secret string) error {
+	// Commands read from stdin in interactive mode keep the secret out of
+	// the process list; -X takes it hex encoded so no quoting is needed
+	cmd := fmt.Sprintf("add-generic-password -U -s %s -a %s -l %q -X %s\n",
+		keyringService, account, "bucket API key", hex.EncodeToString([]byte(secret)))
+	_, err := k.run(cmd, "-i")
+	return err
+}
+
+func (k keychain) Delete(account string) error {
+	_, err := k.run("", "delete-generic-password", "-s", keyringService, "-a", account)
+	if errors.Is(err, ErrCredentialNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (keychain) run(stdin string, args ...string) (string, error) {
+	cmd := exec.Command("security", args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound {
+			return "", ErrCredentialNotFound
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("security %s: %s: %w", args[0], msg, err)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Secret Service (GNOME Keyring, KWallet) through libsecret's secret-tool.
+// Needs a session bus, so it is skipped over SSH and in containers.
+const keyringService = "bucket"
+
+type secretService struct {
+	tool string
+}
+
+func systemStore() CredentialStore {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+	tool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil
+	}
+	return &secretService{tool: tool}
+}
+
+func (s *secretService) Name() string { return "secret-service" }
+
+func (s *secretService) Get(account string) (string, error) {
+	out, errOut, err := s.run("", "lookup", "service", keyringService, "account", account)
+	if err != nil {
+		// A missing item is a silent exit 1. A locked or unreachable keyring
+		// says why on stderr, and must not look like being logged out.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && out == "" && errOut == "" {
+			return "", ErrCredentialNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(out, "\n"), nil
+}
+
+func (s *secretService) Set(account, secret string) error {
+	// The secret goes in on stdin, never on the command line
+	_, _, err := s.run(secret, "store", "--label", "bucket API key ("+account+")",
+		"service", keyringService, "account", account)
+	return err
+}
+
+func (s *secretService) Delete(account string) error {
+	_, _, err := s.run("", "clear", "service", keyringService, "account", account)
+	return err
+}
+
+// run returns secret-tool's stdout and stderr, the latter also folded into
+// the error when it fails
+func (s *secretService) run(stdin string, args ...string) (string, string, error) {
+	cmd := exec.Command(s.tool, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	msg := strings.TrimSpace(stderr.String())
+	if err != nil && msg != "" {
+		err = fmt.Errorf("secret-tool %s: %s: %w", args[0], msg, err)
+	}
+	return stdout.String(), msg, err
+}
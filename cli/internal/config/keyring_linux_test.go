@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSecretTool is a secret-tool stand-in running script
+func fakeSecretTool(t *testing.T, script string) *secretService {
+	t.Helper()
+	tool := filepath.Join(t.TempDir(), "secret-tool")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return &secretService{tool: tool}
+}
+
+func TestSecretServiceGet(t *testing.T) {
+	got, err := fakeSecretTool(t, "printf 'the-key\\n'").Get("profile/default")
+	if err != nil || got != "the-key" {
+		t.Fatalf("Get = %q, %v", got, err)
+	}
+
+	// What secret-tool does when there is no such item
+	if _, err := fakeSecretTool(t, "exit 1").Get("profile/default"); !errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("Get of a missing item: %v", err)
+	}
+
+	// A locked or unreachable keyring is not the same as no key
+	locked := fakeSecretTool(t, "echo 'Cannot get secret of a locked object' >&2; exit 1")
+	if _, err := locked.Get("profile/default"); err == nil || errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("Get from a locked keyring: %v", err)
+	}
+	if _, err := fakeSecretTool(t, "exit 2").Get("profile/default"); err == nil || errors.Is(err, ErrCredentialNotFound) {
+		t.Fatalf("Get with an unexpected exit status: %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package config
+
+// No OS keyring here, keys go to the encrypted file or config.json
+func systemStore() CredentialStore {
+	return nil
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// Windows Credential Manager, as generic credentials named "bucket:<account>"
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredWrite  = advapi32.NewProc("CredWriteW")
+	procCredRead   = advapi32.NewProc("CredReadW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2
+	errorNotFound           = syscall.Errno(1168)
+)
+
+// credential mirrors CREDENTIALW
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+type credManager struct{}
+
+func systemStore() CredentialStore {
+	if advapi32.Load() != nil || procCredRead.Find() != nil {
+		return nil
+	}
+	return credManager{}
+}
+
+func (credManager) Name() string { return "wincred" }
+
+func (credManager) Get(account string) (string, error) {
+	target, err := syscall.UTF16PtrFromString("bucket:" + account)
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	r, _, err := procCredRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if r == 0 {
+		if errors.Is(err, errorNotFound) {
+			return "", ErrCredentialNotFound
+		}
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	blob := unsafe.Slice(cred.CredentialBlob, cred.CredentialBlobSize)
+	return string(blob), nil
+}
+
+func (credManager) Set(account, secret string) error {
+	target, err := syscall.UTF16PtrFromString("bucket:" + account)
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if len(blob) > 0 {
+		cred.CredentialBlob = &blob[0]
+	}
+
+	r, _, err := procCredWrite.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+func (credManager) Delete(account string) error {
+	target, err := syscall.UTF16PtrFromString("bucket:" + account)
+	if err != nil {
+		return err
+	}
+
+	r, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if r == 0 && !errors.Is(err, errorNotFound) {
+		return err
+	}
+	return nil
+}
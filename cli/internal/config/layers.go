@@ -0,0 +1,205 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Settings are resolved in layers, each one overriding those below it:
+// command-line flags, BUCKET_* environment variables, a project file,
+// the profile in the user's config.json, and built-in defaults. Only the
+// user config is ever written back.
+const (
+	EnvAPIKey        = "BUCKET_API_KEY"
+	EnvAPIBase       = "BUCKET_API_BASE"
+	EnvProfile       = "BUCKET_PROFILE"
+	EnvUploadWorkers = "BUCKET_UPLOAD_WORKERS"
+	EnvConfig        = "BUCKET_CONFIG"
+
+	// What .env files called BUCKET_API_BASE before. Still read when that
+	// is unset, see Config.Deprecated.
+	EnvLegacyAPIBase = "BASEURL"
+)
+
+// ProjectFileName is looked for in the working directory and its parents.
+// It is meant to be committed, so it cannot hold an API key, nor point a
+// profile's key at another server.
+const ProjectFileName = ".bucket.json"
+
+// Project is the content of a project file
+type Project struct {
+	APIBase       string `json:"api_base,omitempty"`
+	Profile       string `json:"profile,omitempty"`
+	UploadWorkers int    `json:"upload_workers,omitempty"`
+}
+
+// ErrForeignAPIBase is returned by Load when a project file points the
+// profile's saved API key at a server it was not saved for
+var ErrForeignAPIBase = errors.New("not sending the saved API key there")
+
+// Source names a configuration layer
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceUser    Source = "user config"
+	SourceProject Source = "project file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Origin is where one setting's value came from. Detail is the file,
+// variable or flag that set it.
+type Origin struct {
+	Source Source `json:"source"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (o Origin) String() string {
+	if o.Detail == "" {
+		return string(o.Source)
+	}
+	return string(o.Source) + " " + o.Detail
+}
+
+// Overridden reports whether the setting came from above the user config,
+// in which case Save leaves the saved value alone
+func (c *Config) Overridden(key string) bool {
+	switch c.Origins[key].Source {
+	case SourceProject, SourceEnv, SourceFlag:
+		return true
+	}
+	return false
+}
+
+// The project file is looked up once, it cannot change mid-run
+var (
+	project     *Project
+	projectPath string
+	projectErr  error
+	projectDone bool
+)
+
+// FindProject returns the nearest project file, or nil if there is none
+func FindProject() (*Project, string, error) {
+	if projectDone {
+		return project, projectPath, projectErr
+	}
+	projectDone = true
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, "", nil
+	}
+	for {
+		path := filepath.Join(dir, ProjectFileName)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			var p Project
+			if err := json.Unmarshal(data, &p); err != nil {
+				projectErr = fmt.Errorf("reading %s: %w", path, err)
+				return nil, "", projectErr
+			}
+			if p.Profile != "" {
+				if err := ValidProfileName(p.Profile); err != nil {
+					projectErr = fmt.Errorf("%s: %w", path, err)
+					return nil, "", projectErr
+				}
+			}
+			project, projectPath = &p, path
+			return project, projectPath, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, "", nil
+		}
+		dir = parent
+	}
+}
+
+// activeProfile picks the profile to load and says why
+func (f *File) activeProfile() (string, Origin) {
+	if profileOverride != "" {
+		return profileOverride, Origin{SourceFlag, "--profile"}
+	}
+	if name := os.Getenv(EnvProfile); name != "" {
+		return name, Origin{SourceEnv, EnvProfile}
+	}
+	if p, path, _ := FindProject(); p != nil && p.Profile != "" {
+		return p.Profile, Origin{SourceProject, path}
+	}
+	return f.DefaultProfile, Origin{SourceUser, Path()}
+}
+
+// applyLayers resolves cfg's settings on top of the saved profile,
+// recording where each one came from
+func applyLayers(cfg *Config) error {
+	user := Origin{SourceUser, Path()}
+	def := Origin{Source: SourceDefault}
+
+	cfg.Origins["api_base"] = user
+	if cfg.APIBase == "" {
+		cfg.APIBase = DefaultAPIBase
+		cfg.Origins["api_base"] = def
+	}
+	cfg.Origins["api_key"] = def
+	if cfg.APIKey != "" || cfg.APIKeyRef != "" {
+		cfg.Origins["api_key"] = user
+	}
+	cfg.Origins["upload_workers"] = def
+	if cfg.UploadWorkers != 0 {
+		cfg.Origins["upload_workers"] = user
+	}
+
+	p, path, err := FindProject()
+	if err != nil {
+		return err
+	}
+	if p != nil {
+		if p.APIBase != "" {
+			cfg.APIBaseOverride = strings.TrimRight(p.APIBase, "/")
+			cfg.Origins["api_base"] = Origin{SourceProject, path}
+		}
+		if p.UploadWorkers != 0 {
+			cfg.UploadWorkers = p.UploadWorkers
+			cfg.Origins["upload_workers"] = Origin{SourceProject, path}
+		}
+	}
+
+	baseVar := EnvAPIBase
+	if os.Getenv(EnvAPIBase) == "" && os.Getenv(EnvLegacyAPIBase) != "" {
+		baseVar = EnvLegacyAPIBase
+		cfg.Deprecated = append(cfg.Deprecated, fmt.Sprintf("%s is deprecated, set %s instead", EnvLegacyAPIBase, EnvAPIBase))
+	}
+	if v := os.Getenv(baseVar); v != "" {
+		cfg.APIBaseOverride = strings.TrimRight(v, "/")
+		cfg.Origins["api_base"] = Origin{SourceEnv, baseVar}
+	}
+	if v := os.Getenv(EnvAPIKey); v != "" {
+		cfg.APIKey = v
+		cfg.Origins["api_key"] = Origin{SourceEnv, EnvAPIKey}
+	}
+	if v := os.Getenv(EnvUploadWorkers); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return fmt.Errorf("%s must be a positive number, not %q", EnvUploadWorkers, v)
+		}
+		cfg.UploadWorkers = n
+		cfg.Origins["upload_workers"] = Origin{SourceEnv, EnvUploadWorkers}
+	}
+
+	// Anyone can commit a project file, so its server only gets a saved key
+	// if that is where the key was saved for
+	if cfg.Origins["api_base"].Source == SourceProject && cfg.Origins["api_key"].Source == SourceUser &&
+		cfg.APIBaseOverride != strings.TrimRight(cfg.APIBase, "/") {
+		return fmt.Errorf("%s sets api_base to %s, but profile %q is logged in to %s: %w. "+
+			"Name a profile for that server in the project file, or set %s", path, cfg.APIBaseOverride, cfg.Profile, cfg.APIBase, ErrForeignAPIBase, EnvAPIKey)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProjectCannotRedirectSavedKey(t *testing.T) {
+	isolate(t)
+	if err := Save(&Config{APIBase: "https://api.example.test", APIKey: "k", Profile: DefaultProfile}); err != nil {
+		t.Fatal(err)
+	}
+
+	project, projectPath = &Project{APIBase: "https://elsewhere.test"}, "/repo/.bucket.json"
+	if _, err := Load(); !errors.Is(err, ErrForeignAPIBase) {
+		t.Fatalf("Load with a foreign project api_base: %v", err)
+	}
+
+	project.APIBase = "https://api.example.test/"
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Origins["api_base"].Source != SourceProject || cfg.APIKey != "k" {
+		t.Fatalf("api_base from %s, key %q", cfg.Origins["api_base"], cfg.APIKey)
+	}
+
+	// A key from the environment was meant for whatever server is chosen
+	project.APIBase = "https://elsewhere.test"
+	t.Setenv(EnvAPIKey, "env-key")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BaseURL() != "https://elsewhere.test" || cfg.APIKey != "env-key" {
+		t.Fatalf("talking to %s with key %q", cfg.BaseURL(), cfg.APIKey)
+	}
+}
+
+func TestLegacyBaseURL(t *testing.T) {
+	isolate(t)
+
+	t.Setenv(EnvLegacyAPIBase, "http://legacy.test/")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BaseURL() != "http://legacy.test" || cfg.Origins["api_base"].Detail != EnvLegacyAPIBase || len(cfg.Deprecated) != 1 {
+		t.Fatalf("api_base %s from %s, warnings %q", cfg.BaseURL(), cfg.Origins["api_base"], cfg.Deprecated)
+	}
+
+	t.Setenv(EnvAPIBase, "http://new.test")
+	if cfg, err = Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.BaseURL() != "http://new.test" || len(cfg.Deprecated) != 0 {
+		t.Fatalf("api_base %s, warnings %q", cfg.BaseURL(), cfg.Deprecated)
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+)
+
+// DefaultProfile is the profile a fresh or migrated config starts with
+const DefaultProfile = "default"
+
+// File is everything in config.json: one Config per named profile
+type File struct {
+	DefaultProfile  string             `json:"default_profile"`
+	CredentialStore string             `json:"credential_store,omitempty"` // Where API keys go, see StoreAuto
+	Profiles        map[string]*Config `json:"profiles"`
+}
+
+// ErrNoProfile is returned by Load when the chosen profile does not exist
+var ErrNoProfile = errors.New("no profile named")
+
+// profileOverride is the profile for this run, see SetProfile
+var profileOverride string
+
+// SetProfile makes Load use the named profile instead of the default one
+func SetProfile(name string) {
+	profileOverride = name
+}
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidProfileName rejects names that would be awkward on a command line
+func ValidProfileName(name string) error {
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// LoadFile reads every profile. A missing file gives an empty default
+// profile; a single-account file from before profiles becomes the default
+// profile and is rewritten in the new layout.
+func LoadFile() (*File, error) {
+	data, err := os.ReadFile(configPath())
+	if os.IsNotExist(err) {
+		return &File{DefaultProfile: DefaultProfile, Profiles: map[string]*Config{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Profiles != nil {
+		if f.DefaultProfile == "" {
+			f.DefaultProfile = DefaultProfile
+		}
+		return &f, nil
+	}
+
+	var legacy Config
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+	f = File{
+		DefaultProfile: DefaultProfile,
+		Profiles:       map[string]*Config{DefaultProfile: &legacy},
+	}
+	_ = f.Save() // Best effort, the next save migrates it otherwise
+	return &f, nil
+}
+
+// Save writes every profile back to config.json
+func (f *File) Save() error {
+	path := configPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
+
+// Active is the profile Load returns
+func (f *File) Active() string {
+	name, _ := f.activeProfile()
+	return name
+}
+
+// Remove drops a profile along with its stored API key
+func (f *File) Remove(name string) error {
+	if p := f.Profiles[name]; p != nil && p.APIKeyRef != "" {
+		deleteRef(p.APIKeyRef)
+	}
+	delete(f.Profiles, name)
+	return f.Save()
+}
+
+// Names lists the profiles alphabetically
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
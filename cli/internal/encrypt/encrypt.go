@@ -0,0 +1,391 @@
+package encrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Stream format:
+//
+//	header  (v1: magic "BKTENC1\x00", v2: see recipients.go)
+//	chunk 0 .. chunk n-1
+//
+// Every chunk holds up to ChunkSize bytes of plaintext sealed with
+// AES-256-GCM. The 12-byte nonce is an 11-byte big-endian chunk counter
+// followed by 0x01 on the final chunk and 0x00 otherwise, so chunks cannot be
+// reordered, dropped or the stream truncated without failing authentication.
+// v2 chunks carry the SHA-256 of the whole header as associated data, so the
+// recipient stanzas cannot be altered either.
+// The file key is random per upload and never sent to the server: v1 hands
+// it out inside the share secret, v2 wraps it for X25519 recipients.
+const (
+	ChunkSize = 64 * 1024
+	KeySize   = 32
+
+	tagSize     = 16
+	cipherChunk = ChunkSize + tagSize
+)
+
+var magic = []byte("BKTENC1\x00")
+
+var (
+	ErrNotEncrypted = errors.New("not an encrypted bucket object")
+	ErrKeyRequired  = errors.New("object is encrypted with a shared key, use the full secret including the part after '#'")
+)
+
+// NewKey returns a fresh random file key
+func NewKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EncodeKey renders a key for sharing alongside the download secret
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("invalid encryption key")
+	}
+	return key, nil
+}
+
+// EncryptedSize is the exact number of bytes a plaintext of the given size
+// takes once encrypted for the given number of recipients (0 for a key shared
+// in the secret). An empty file still carries one (empty) final chunk.
+func EncryptedSize(plainSize int64, recipients int) int64 {
+	return int64(headerSize(recipients)) + plainSize + chunkCount(plainSize)*tagSize
+}
+
+func chunkCount(plainSize int64) int64 {
+	n := (plainSize + ChunkSize - 1) / ChunkSize
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// headerAD is the associated data every chunk is sealed with: nothing for
+// v1, whose header is just the magic, the header's SHA-256 for v2
+func headerAD(header []byte) []byte {
+	if bytes.Equal(header, magic) {
+		return nil
+	}
+	sum := sha256.Sum256(header)
+	return sum[:]
+}
+
+func chunkNonce(index int64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], uint64(index))
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// ReaderAt exposes the encrypted form of a plaintext io.ReaderAt. Each chunk
+// is encrypted independently, so any byte range can be produced on demand,
+// which is what lets multipart and resumed uploads work on encrypted files.
+type ReaderAt struct {
+	src       io.ReaderAt
+	plainSize int64
+	chunks    int64
+	header    []byte
+	ad        []byte
+	aead      cipher.AEAD
+}
+
+// NewReaderAt encrypts src under key, to be decrypted with that same key
+func NewReaderAt(src io.ReaderAt, plainSize int64, key []byte) (*ReaderAt, error) {
+	return newReaderAt(src, plainSize, key, magic)
+}
+
+// NewRecipientReaderAt encrypts src under key and wraps key for each
+// recipient, so only holders of a matching identity can decrypt it
+func NewRecipientReaderAt(src io.ReaderAt, plainSize int64, key []byte, recipients []*ecdh.PublicKey) (*ReaderAt, error) {
+	header, err := recipientHeader(key, recipients)
+	if err != nil {
+		return nil, err
+	}
+	return newReaderAt(src, plainSize, key, header)
+}
+
+func newReaderAt(src io.ReaderAt, plainSize int64, key, header []byte) (*ReaderAt, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &ReaderAt{
+		src:       src,
+		plainSize: plainSize,
+		chunks:    chunkCount(plainSize),
+		header:    header,
+		ad:        headerAD(header),
+		aead:      aead,
+	}, nil
+}
+
+// Size is the length of the encrypted stream
+func (r *ReaderAt) Size() int64 {
+	return int64(len(r.header)) + r.plainSize + r.chunks*tagSize
+}
+
+func (r *ReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	size := r.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < size {
+		if off < int64(len(r.header)) {
+			c := copy(p[n:], r.header[off:])
+			n += c
+			off += int64(c)
+			continue
+		}
+
+		rel := off - int64(len(r.header))
+		index := rel / cipherChunk
+
+		sealed, err := r.sealChunk(index)
+		if err != nil {
+			return n, err
+		}
+
+		c := copy(p[n:], sealed[rel%cipherChunk:])
+		n += c
+		off += int64(c)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *ReaderAt) sealChunk(index int64) ([]byte, error) {
+	start := index * ChunkSize
+	length := int64(ChunkSize)
+	if start+length > r.plainSize {
+		length = r.plainSize - start
+	}
+
+	plain := make([]byte, length)
+	if _, err := r.src.ReadAt(plain, start); err != nil && !(err == io.EOF && length == 0) {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	last := index == r.chunks-1
+	return r.aead.Seal(plain[:0], chunkNonce(index, last), plain, r.ad), nil
+}
+
+// NewReader encrypts a stream of unknown length. With recipients the file
+// key is wrapped for them (v2), otherwise the caller shares key itself (v1).
+// The output is byte-for-byte what ReaderAt produces for the same input.
+func NewReader(src io.Reader, key []byte, recipients []*ecdh.PublicKey) (io.Reader, error) {
+	header := magic
+	if len(recipients) > 0 {
+		var err error
+		if header, err = recipientHeader(key, recipients); err != nil {
+			return nil, err
+		}
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		src:     bufio.NewReaderSize(src, ChunkSize+1),
+		aead:    aead,
+		ad:      headerAD(header),
+		pending: header,
+		chunk:   make([]byte, ChunkSize),
+		sealed:  make([]byte, 0, cipherChunk),
+	}, nil
+}
+
+type streamReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	ad      []byte
+	pending []byte
+	chunk   []byte
+	sealed  []byte
+	index   int64
+	done    bool
+	err     error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.sealNext()
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *streamReader) sealNext() {
+	n, err := io.ReadFull(s.src, s.chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		s.err = err
+		return
+	}
+
+	last := err != nil
+	if !last {
+		if _, peekErr := s.src.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+
+	s.pending = s.aead.Seal(s.sealed[:0], chunkNonce(s.index, last), s.chunk[:n], s.ad)
+	s.index++
+	s.done = last
+}
+
+// Decrypt streams an encrypted object from src to dst, failing if any chunk
+// was altered or the stream was cut short. Objects sealed for recipients are
+// opened with the first identity that matches; key may then be nil.
+func Decrypt(dst io.Writer, src io.Reader, key []byte, identities []*ecdh.PrivateKey) error {
+	br := bufio.NewReaderSize(src, cipherChunk+1)
+
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, head); err != nil {
+		return ErrNotEncrypted
+	}
+
+	var ad []byte
+	switch {
+	case bytes.Equal(head, magic):
+		if key == nil {
+			return ErrKeyRequired
+		}
+	case bytes.Equal(head, recipientMagic):
+		unwrapped, header, err := openRecipientHeader(br, identities)
+		if err != nil {
+			return err
+		}
+		key, ad = unwrapped, headerAD(header)
+	default:
+		return ErrNotEncrypted
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, cipherChunk)
+	for index := int64(0); ; index++ {
+		n, err := io.ReadFull(br, buf)
+		if err == io.EOF {
+			return fmt.Errorf("encrypted stream is truncated")
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		last := err == io.ErrUnexpectedEOF
+		if !last {
+			if _, peekErr := br.Peek(1); peekErr == io.EOF {
+				last = true
+			}
+		}
+
+		plain, err := aead.Open(buf[:0], chunkNonce(index, last), buf[:n], ad)
+		if err != nil {
+			return fmt.Errorf("decryption failed at chunk %d: wrong key or corrupted data", index)
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// DecryptFile replaces the encrypted file at path with its plaintext. The
+// plaintext is written next to it first so a bad key leaves path untouched.
+func DecryptFile(path string, key []byte, identities []*ecdh.PrivateKey) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmpPath := path + ".decrypting"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if err := Decrypt(out, in, key, identities); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	in.Close()
+	return os.Rename(tmpPath, path)
+}
+
+// SplitSecret separates a shared secret of the form "<secret>#<key>" into
+// the part the server checks and the client-side decryption key
+func SplitSecret(shared string) (string, string) {
+	for i := len(shared) - 1; i >= 0; i-- {
+		if shared[i] == '#' {
+			return shared[:i], shared[i+1:]
+		}
+	}
+	return shared, ""
+}
+
+// JoinSecret is the inverse of SplitSecret
+func JoinSecret(secret string, key []byte) string {
+	return secret + "#" + EncodeKey(key)
+}
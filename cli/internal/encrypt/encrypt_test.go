@@ -0,0 +1,151 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// plainSizes covers the empty stream, exact chunk multiples and odd tails
+var plainSizes = []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3 * ChunkSize, 3*ChunkSize + 17}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := NewKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// sealAll returns the whole encrypted form of plain from a ReaderAt
+func sealAll(t *testing.T, plain, key []byte) []byte {
+	t.Helper()
+	r, err := NewReaderAt(bytes.NewReader(plain), int64(len(plain)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	key := newTestKey(t)
+	for _, n := range plainSizes {
+		plain := randomBytes(t, n)
+
+		r, err := NewReaderAt(bytes.NewReader(plain), int64(n), key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := EncryptedSize(int64(n), 0); got != r.Size() {
+			t.Errorf("%d bytes: EncryptedSize = %d, ReaderAt.Size = %d", n, got, r.Size())
+		}
+
+		sealed := sealAll(t, plain, key)
+		if int64(len(sealed)) != r.Size() {
+			t.Errorf("%d bytes: read %d encrypted bytes, Size says %d", n, len(sealed), r.Size())
+		}
+
+		stream, err := NewReader(bytes.NewReader(plain), key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streamed, err := io.ReadAll(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(streamed, sealed) {
+			t.Errorf("%d bytes: NewReader output differs from ReaderAt", n)
+		}
+
+		var out bytes.Buffer
+		if err := Decrypt(&out, bytes.NewReader(sealed), key, nil); err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if !bytes.Equal(out.Bytes(), plain) {
+			t.Errorf("%d bytes: decrypted plaintext differs", n)
+		}
+	}
+}
+
+func TestReadAtAnyOffset(t *testing.T) {
+	key := newTestKey(t)
+	plain := randomBytes(t, 2*ChunkSize+100)
+	sealed := sealAll(t, plain, key)
+
+	r, err := NewReaderAt(bytes.NewReader(plain), int64(len(plain)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ranges straddling the header and chunk boundaries, as parts would
+	for _, off := range []int{0, 3, len(magic), len(magic) + cipherChunk - 1, len(magic) + cipherChunk + 5, len(sealed) - 1} {
+		buf := make([]byte, 1000)
+		n, err := r.ReadAt(buf, int64(off))
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], sealed[off:off+n]) {
+			t.Errorf("ReadAt(%d) differs from the sequential encryption", off)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	key := newTestKey(t)
+	plain := randomBytes(t, 3*ChunkSize)
+	sealed := sealAll(t, plain, key)
+
+	header := len(magic)
+	chunk := func(i int) []byte { return sealed[header+i*cipherChunk : header+(i+1)*cipherChunk] }
+	join := func(parts ...[]byte) []byte { return bytes.Join(append([][]byte{sealed[:header]}, parts...), nil) }
+
+	cases := map[string][]byte{
+		"truncated at a chunk boundary": sealed[:header+2*cipherChunk],
+		"truncated after the header":    sealed[:header],
+		"truncated mid-chunk":           sealed[:len(sealed)-10],
+		"chunks reordered":              join(chunk(1), chunk(0), chunk(2)),
+		"chunk dropped":                 join(chunk(0), chunk(2)),
+		"chunk repeated":                join(chunk(0), chunk(0), chunk(1), chunk(2)),
+	}
+	for name, data := range cases {
+		if err := Decrypt(io.Discard, bytes.NewReader(data), key, nil); err == nil {
+			t.Errorf("%s: decrypted without error", name)
+		}
+	}
+
+	if err := Decrypt(io.Discard, bytes.NewReader(sealed), newTestKey(t), nil); err == nil {
+		t.Error("decrypted with the wrong key")
+	}
+	if err := Decrypt(io.Discard, bytes.NewReader(sealed), nil, nil); err != ErrKeyRequired {
+		t.Errorf("decrypting without a key: %v, want ErrKeyRequired", err)
+	}
+	if err := Decrypt(io.Discard, bytes.NewReader(plain), key, nil); err != ErrNotEncrypted {
+		t.Errorf("decrypting plaintext: %v, want ErrNotEncrypted", err)
+	}
+}
+
+func TestSecretRoundTrip(t *testing.T) {
+	key := newTestKey(t)
+	secret, encoded := SplitSecret(JoinSecret("abc123", key))
+	if secret != "abc123" {
+		t.Fatalf("secret = %q", secret)
+	}
+	decoded, err := DecodeKey(encoded)
+	if err != nil || !bytes.Equal(decoded, key) {
+		t.Fatalf("DecodeKey = %x, %v", decoded, err)
+	}
+}
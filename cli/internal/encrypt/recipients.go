@@ -0,0 +1,217 @@
+package encrypt
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Recipient header (v2):
+//
+//	magic "BKTENC2\x00"
+//	uint16 big-endian stanza count
+//	per recipient: 32-byte ephemeral X25519 public key | 48-byte wrapped key
+//
+// The wrapping key for a stanza is HKDF-SHA256 over the X25519 shared secret
+// with salt = ephemeral public || recipient public. The file key is sealed
+// with AES-256-GCM under an all-zero nonce, which is safe because every
+// wrapping key is used exactly once. The chunks that follow authenticate the
+// whole header, see headerAD.
+const (
+	RecipientPrefix = "bkpub-"
+	IdentityPrefix  = "BKSEC-"
+
+	stanzaSize = 32 + KeySize + tagSize
+	wrapInfo   = "bucket x25519 file key v1"
+)
+
+var recipientMagic = []byte("BKTENC2\x00")
+
+var ErrNoIdentity = errors.New("object is encrypted for other recipients, no local identity matches")
+
+func headerSize(recipients int) int {
+	if recipients == 0 {
+		return len(magic)
+	}
+	return len(recipientMagic) + 2 + recipients*stanzaSize
+}
+
+// GenerateIdentity creates a new X25519 private key
+func GenerateIdentity() (*ecdh.PrivateKey, error) {
+	return ecdh.X25519().GenerateKey(rand.Reader)
+}
+
+// FormatIdentity renders a private key the way it is stored on disk
+func FormatIdentity(k *ecdh.PrivateKey) string {
+	return IdentityPrefix + base64.RawURLEncoding.EncodeToString(k.Bytes())
+}
+
+// FormatRecipient renders a public key for `bucket push --to`
+func FormatRecipient(pub *ecdh.PublicKey) string {
+	return RecipientPrefix + base64.RawURLEncoding.EncodeToString(pub.Bytes())
+}
+
+func ParseRecipient(s string) (*ecdh.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, RecipientPrefix) {
+		return nil, fmt.Errorf("invalid recipient %q: must start with %s", s, RecipientPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, RecipientPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q", s)
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+func ParseIdentity(s string) (*ecdh.PrivateKey, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, IdentityPrefix) {
+		return nil, fmt.Errorf("invalid identity: must start with %s", IdentityPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, IdentityPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity")
+	}
+	return ecdh.X25519().NewPrivateKey(raw)
+}
+
+// LoadIdentities reads every identity in an identities file. Blank lines and
+// lines starting with '#' are skipped. A missing file is not an error.
+func LoadIdentities(path string) ([]*ecdh.PrivateKey, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ids []*ecdh.PrivateKey
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		id, err := ParseIdentity(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, scanner.Err()
+}
+
+// AppendIdentity adds an identity to the identities file, creating it
+// (mode 0600) if needed
+func AppendIdentity(path string, k *ecdh.PrivateKey) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "# created: %s\n# public key: %s\n%s\n",
+		time.Now().UTC().Format(time.RFC3339), FormatRecipient(k.PublicKey()), FormatIdentity(k))
+	return err
+}
+
+func recipientHeader(key []byte, recipients []*ecdh.PublicKey) ([]byte, error) {
+	if len(recipients) == 0 || len(recipients) > 0xffff {
+		return nil, fmt.Errorf("need between 1 and 65535 recipients")
+	}
+
+	header := make([]byte, 0, headerSize(len(recipients)))
+	header = append(header, recipientMagic...)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(recipients)))
+
+	for _, pub := range recipients {
+		eph, err := GenerateIdentity()
+		if err != nil {
+			return nil, err
+		}
+		aead, err := wrapAEAD(eph, pub)
+		if err != nil {
+			return nil, err
+		}
+		header = append(header, eph.PublicKey().Bytes()...)
+		header = aead.Seal(header, make([]byte, 12), key, nil)
+	}
+	return header, nil
+}
+
+// openRecipientHeader reads the stanzas following the v2 magic and returns
+// the file key from the first one an identity can unwrap, along with the
+// complete header the chunks are bound to
+func openRecipientHeader(r io.Reader, identities []*ecdh.PrivateKey) ([]byte, []byte, error) {
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, nil, ErrNotEncrypted
+	}
+
+	header := make([]byte, headerSize(int(count)))
+	copy(header, recipientMagic)
+	binary.BigEndian.PutUint16(header[len(recipientMagic):], count)
+	stanzas := header[len(recipientMagic)+2:]
+	if _, err := io.ReadFull(r, stanzas); err != nil {
+		return nil, nil, ErrNotEncrypted
+	}
+
+	for i := 0; i < int(count); i++ {
+		stanza := stanzas[i*stanzaSize : (i+1)*stanzaSize]
+		eph, err := ecdh.X25519().NewPublicKey(stanza[:32])
+		if err != nil {
+			continue
+		}
+
+		for _, id := range identities {
+			shared, err := id.ECDH(eph)
+			if err != nil {
+				continue
+			}
+			aead, err := newWrapAEAD(shared, eph, id.PublicKey())
+			if err != nil {
+				return nil, nil, err
+			}
+			key, err := aead.Open(nil, make([]byte, 12), stanza[32:], nil)
+			if err == nil {
+				return key, header, nil
+			}
+		}
+	}
+	return nil, nil, ErrNoIdentity
+}
+
+func wrapAEAD(eph *ecdh.PrivateKey, pub *ecdh.PublicKey) (cipher.AEAD, error) {
+	shared, err := eph.ECDH(pub)
+	if err != nil {
+		return nil, err
+	}
+	return newWrapAEAD(shared, eph.PublicKey(), pub)
+}
+
+func newWrapAEAD(shared []byte, eph, pub *ecdh.PublicKey) (cipher.AEAD, error) {
+	salt := append(eph.Bytes(), pub.Bytes()...)
+	key, err := hkdf.Key(sha256.New, shared, salt, wrapInfo, KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return newAEAD(key)
+}
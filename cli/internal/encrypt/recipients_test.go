@@ -0,0 +1,161 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func newTestIdentity(t *testing.T) *ecdh.PrivateKey {
+	t.Helper()
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// sealFor returns plain encrypted for the given identities' public keys
+func sealFor(t *testing.T, plain []byte, ids ...*ecdh.PrivateKey) []byte {
+	t.Helper()
+	var recipients []*ecdh.PublicKey
+	for _, id := range ids {
+		recipients = append(recipients, id.PublicKey())
+	}
+	r, err := NewRecipientReaderAt(bytes.NewReader(plain), int64(len(plain)), newTestKey(t), recipients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := EncryptedSize(int64(len(plain)), len(recipients)); got != r.Size() {
+		t.Errorf("EncryptedSize = %d, ReaderAt.Size = %d", got, r.Size())
+	}
+	out, err := io.ReadAll(io.NewSectionReader(r, 0, r.Size()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestRecipientsRoundTrip(t *testing.T) {
+	alice, bob, carol := newTestIdentity(t), newTestIdentity(t), newTestIdentity(t)
+	plain := randomBytes(t, 2*ChunkSize+3)
+	sealed := sealFor(t, plain, alice, bob, carol)
+
+	for name, ids := range map[string][]*ecdh.PrivateKey{
+		"first recipient":   {alice},
+		"middle recipient":  {bob},
+		"last recipient":    {carol},
+		"among other names": {newTestIdentity(t), carol},
+	} {
+		var out bytes.Buffer
+		if err := Decrypt(&out, bytes.NewReader(sealed), nil, ids); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(out.Bytes(), plain) {
+			t.Errorf("%s: decrypted plaintext differs", name)
+		}
+	}
+}
+
+func TestRecipientsStreamMatchesSize(t *testing.T) {
+	alice := newTestIdentity(t)
+	plain := randomBytes(t, ChunkSize)
+
+	stream, err := NewReader(bytes.NewReader(plain), newTestKey(t), []*ecdh.PublicKey{alice.PublicKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(sealed)) != EncryptedSize(int64(len(plain)), 1) {
+		t.Errorf("streamed %d bytes, EncryptedSize says %d", len(sealed), EncryptedSize(int64(len(plain)), 1))
+	}
+
+	var out bytes.Buffer
+	if err := Decrypt(&out, bytes.NewReader(sealed), nil, []*ecdh.PrivateKey{alice}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), plain) {
+		t.Error("decrypted plaintext differs")
+	}
+}
+
+func TestRecipientsRejectOthers(t *testing.T) {
+	sealed := sealFor(t, []byte("for alice only"), newTestIdentity(t))
+
+	err := Decrypt(io.Discard, bytes.NewReader(sealed), nil, []*ecdh.PrivateKey{newTestIdentity(t)})
+	if !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("stranger decrypting: %v, want ErrNoIdentity", err)
+	}
+	if err := Decrypt(io.Discard, bytes.NewReader(sealed), newTestKey(t), nil); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("decrypting with a key and no identity: %v, want ErrNoIdentity", err)
+	}
+}
+
+func TestRecipientHeaderIsAuthenticated(t *testing.T) {
+	alice, bob := newTestIdentity(t), newTestIdentity(t)
+	plain := randomBytes(t, 100)
+	sealed := sealFor(t, plain, alice, bob)
+
+	stanzas := len(recipientMagic) + 2
+	withHeader := func(edit func(b []byte) []byte) []byte {
+		return edit(append([]byte(nil), sealed...))
+	}
+
+	cases := map[string][]byte{
+		// Bob's stanza still unwraps for alice's file key, only the
+		// header hash bound to every chunk can notice
+		"bob's stanza dropped": withHeader(func(b []byte) []byte {
+			binary.BigEndian.PutUint16(b[len(recipientMagic):], 1)
+			return append(b[:stanzas+stanzaSize], b[stanzas+2*stanzaSize:]...)
+		}),
+		"bob's stanza altered": withHeader(func(b []byte) []byte {
+			b[stanzas+stanzaSize+40] ^= 1
+			return b
+		}),
+		"stanzas swapped": withHeader(func(b []byte) []byte {
+			first := append([]byte(nil), b[stanzas:stanzas+stanzaSize]...)
+			copy(b[stanzas:], b[stanzas+stanzaSize:stanzas+2*stanzaSize])
+			copy(b[stanzas+stanzaSize:], first)
+			return b
+		}),
+	}
+	for name, data := range cases {
+		err := Decrypt(io.Discard, bytes.NewReader(data), nil, []*ecdh.PrivateKey{alice})
+		if err == nil || errors.Is(err, ErrNoIdentity) {
+			t.Errorf("%s: %v, want a chunk authentication failure", name, err)
+		}
+	}
+}
+
+func TestIdentitiesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identities")
+	alice, bob := newTestIdentity(t), newTestIdentity(t)
+	for _, id := range []*ecdh.PrivateKey{alice, bob} {
+		if err := AppendIdentity(path, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids, err := LoadIdentities(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 || !ids[0].Equal(alice) || !ids[1].Equal(bob) {
+		t.Fatalf("loaded %d identities, want alice and bob", len(ids))
+	}
+
+	pub, err := ParseRecipient(FormatRecipient(alice.PublicKey()))
+	if err != nil || !pub.Equal(alice.PublicKey()) {
+		t.Fatalf("ParseRecipient: %v", err)
+	}
+	if _, err := ParseRecipient(FormatIdentity(alice)); err == nil {
+		t.Error("an identity was accepted as a recipient")
+	}
+}
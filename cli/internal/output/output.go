@@ -0,0 +1,342 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+type Format string
+
+const (
+	Text  Format = "text"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	Table Format = "table"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case Text, JSON, YAML, Table:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (want text, json, yaml or table)", s)
+}
+
+// Printer renders command results either as the human text each command
+// prints itself, or as one structured document on stdout. In structured
+// formats all human chatter (prompts, status lines) is moved to stderr via
+// UI so stdout stays machine-readable.
+type Printer struct {
+	Format Format
+	UI     io.Writer // Where human-oriented messages go
+
+	out io.Writer
+}
+
+func New(format Format) *Printer {
+	p := &Printer{Format: format, UI: os.Stdout, out: os.Stdout}
+	if p.Structured() {
+		p.UI = os.Stderr
+	}
+	return p
+}
+
+// Structured reports whether results are emitted as documents
+func (p *Printer) Structured() bool {
+	return p.Format != Text && p.Format != ""
+}
+
+// Result emits v in the structured format, or runs human otherwise
+func (p *Printer) Result(v interface{}, human func()) {
+	if !p.Structured() {
+		human()
+		return
+	}
+	if err := p.emit(v); err != nil {
+		fmt.Fprintln(os.Stderr, "Output error:", err)
+	}
+}
+
+// ErrorBody is the structured shape of a failed command
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Message   string `json:"message"`
+	Detail    string `json:"detail,omitempty"`
+	Status    int    `json:"status,omitempty"`     // HTTP status, for API errors
+	Code      string `json:"code,omitempty"`       // Server error code, for API errors
+	RequestID string `json:"request_id,omitempty"` // For support requests
+	ExitCode  int    `json:"exit_code,omitempty"`
+}
+
+// Error reports a failure: "msg: err" as text, or an error document
+func (p *Printer) Error(msg string, err error) {
+	p.ErrorWith(msg, err, ErrorDetail{})
+}
+
+// ErrorWith is Error with extra fields for the error document. Message and
+// Detail are filled in from msg and err.
+func (p *Printer) ErrorWith(msg string, err error, detail ErrorDetail) {
+	if err != nil {
+		fmt.Fprintln(p.UI, msg+":", err)
+	} else {
+		fmt.Fprintln(p.UI, msg)
+	}
+	if !p.Structured() {
+		return
+	}
+
+	detail.Message = msg
+	if err != nil {
+		detail.Detail = err.Error()
+	}
+	if emitErr := p.emit(ErrorBody{Error: detail}); emitErr != nil {
+		fmt.Fprintln(os.Stderr, "Output error:", emitErr)
+	}
+}
+
+func (p *Printer) emit(v interface{}) error {
+	switch p.Format {
+	case JSON:
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case YAML:
+		doc, err := ordered(v)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		writeYAML(&buf, doc, 0)
+		_, err = p.out.Write(buf.Bytes())
+		return err
+	case Table:
+		doc, err := ordered(v)
+		if err != nil {
+			return err
+		}
+		return writeTable(p.out, doc)
+	}
+	return fmt.Errorf("unknown output format %q", p.Format)
+}
+
+// field keeps JSON object keys in struct order, which a map would lose
+type field struct {
+	key   string
+	value interface{}
+}
+
+// ordered round-trips v through encoding/json so the `json` tags decide
+// names and omissions for every format, then decodes it preserving order
+func ordered(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeValue(dec)
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		if t == '{' {
+			fields := []field{}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, field{key.(string), value})
+			}
+			_, err := dec.Token()
+			return fields, err
+		}
+
+		items := []interface{}{}
+		for dec.More() {
+			item, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := dec.Token()
+		return items, err
+	}
+	return tok, nil
+}
+
+func scalarYAML(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		// A JSON string is a valid YAML double-quoted scalar
+		b, _ := json.Marshal(t)
+		return string(b)
+	case json.Number:
+		return t.String()
+	case bool:
+		if t {
+			return "true"
+		}
+		return "false"
+	}
+	return fmt.Sprint(v)
+}
+
+func writeYAML(w *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat(" ", indent)
+
+	switch t := v.(type) {
+	case []field:
+		if len(t) == 0 {
+			w.WriteString(pad + "{}\n")
+			return
+		}
+		for _, f := range t {
+			writeYAMLEntry(w, pad+f.key+":", f.value, indent)
+		}
+	case []interface{}:
+		if len(t) == 0 {
+			w.WriteString(pad + "[]\n")
+			return
+		}
+		for _, item := range t {
+			if fields, ok := item.([]field); ok && len(fields) > 0 {
+				// First key shares the "- " line, the rest align under it
+				writeYAMLEntry(w, pad+"- "+fields[0].key+":", fields[0].value, indent+2)
+				for _, f := range fields[1:] {
+					writeYAMLEntry(w, pad+"  "+f.key+":", f.value, indent+2)
+				}
+				continue
+			}
+			writeYAMLEntry(w, pad+"-", item, indent)
+		}
+	default:
+		w.WriteString(pad + scalarYAML(v) + "\n")
+	}
+}
+
+func writeYAMLEntry(w *bytes.Buffer, prefix string, v interface{}, indent int) {
+	switch t := v.(type) {
+	case []field:
+		if len(t) == 0 {
+			w.WriteString(prefix + " {}\n")
+			return
+		}
+		w.WriteString(prefix + "\n")
+		writeYAML(w, t, indent+2)
+	case []interface{}:
+		if len(t) == 0 {
+			w.WriteString(prefix + " []\n")
+			return
+		}
+		w.WriteString(prefix + "\n")
+		writeYAML(w, t, indent+2)
+	default:
+		w.WriteString(prefix + " " + scalarYAML(v) + "\n")
+	}
+}
+
+func scalarTable(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return t
+	case []field, []interface{}:
+		b, _ := json.Marshal(plain(t))
+		return string(b)
+	}
+	return scalarYAML(v)
+}
+
+// plain turns ordered values back into maps for compact JSON cells
+func plain(v interface{}) interface{} {
+	switch t := v.(type) {
+	case []field:
+		m := make(map[string]interface{}, len(t))
+		for _, f := range t {
+			m[f.key] = plain(f.value)
+		}
+		return m
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = plain(item)
+		}
+		return out
+	}
+	return v
+}
+
+// writeTable prints a list of objects as rows under their keys, and a single
+// object as KEY/VALUE pairs
+func writeTable(w io.Writer, v interface{}) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	switch t := v.(type) {
+	case []interface{}:
+		// Omitted fields mean rows can differ, so take every key seen
+		var columns []string
+		seen := map[string]bool{}
+		for _, item := range t {
+			fields, _ := item.([]field)
+			for _, f := range fields {
+				if !seen[f.key] {
+					seen[f.key] = true
+					columns = append(columns, f.key)
+				}
+			}
+		}
+		if len(columns) > 0 {
+			fmt.Fprintln(tw, strings.ToUpper(strings.Join(columns, "\t")))
+		}
+
+		for _, item := range t {
+			fields, ok := item.([]field)
+			if !ok {
+				fmt.Fprintln(tw, scalarTable(item))
+				continue
+			}
+
+			values := make(map[string]interface{}, len(fields))
+			for _, f := range fields {
+				values[f.key] = f.value
+			}
+			row := make([]string, len(columns))
+			for i, c := range columns {
+				row[i] = scalarTable(values[c])
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+	case []field:
+		fmt.Fprintln(tw, "KEY\tVALUE")
+		for _, f := range t {
+			fmt.Fprintf(tw, "%s\t%s\n", f.key, scalarTable(f.value))
+		}
+	default:
+		fmt.Fprintln(tw, scalarTable(v))
+	}
+
+	return tw.Flush()
+}
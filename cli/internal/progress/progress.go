@@ -0,0 +1,186 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	ttyInterval = 200 * time.Millisecond
+	logInterval = 10 * time.Second
+
+	// Throughput is averaged over this much recent history
+	window = 5 * time.Second
+
+	barWidth = 24
+)
+
+var spinners = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Bar tracks bytes moved by a transfer and renders them to stderr. On a
+// terminal it redraws a single line; otherwise (CI logs, pipes) it prints a
+// plain status line every few seconds. Bar is an io.Writer so it can sit on
+// the side of a TeeReader or MultiWriter; Write only counts, never fails.
+type Bar struct {
+	label string
+	total int64 // < 0 when unknown
+	done  atomic.Int64
+
+	out     io.Writer
+	tty     bool
+	start   time.Time
+	samples []sample
+
+	stop     chan struct{}
+	stopped  chan struct{}
+	started  bool
+	stopOnce sync.Once
+}
+
+type sample struct {
+	at   time.Time
+	done int64
+}
+
+// New creates a bar for a transfer of total bytes (negative if unknown)
+func New(label string, total int64) *Bar {
+	return &Bar{
+		label:   label,
+		total:   total,
+		out:     os.Stderr,
+		tty:     term.IsTerminal(int(os.Stderr.Fd())),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (b *Bar) Write(p []byte) (int, error) {
+	b.done.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// Add counts bytes moved outside of Write, e.g. already sent before a resume
+func (b *Bar) Add(n int64) {
+	b.done.Add(n)
+}
+
+// Start begins rendering in the background until Stop is called
+func (b *Bar) Start() {
+	b.start = time.Now()
+	b.started = true
+	go b.run()
+}
+
+// Stop renders a final state and ends the line. It does nothing if the bar
+// was never started.
+func (b *Bar) Stop() {
+	if !b.started {
+		return
+	}
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		<-b.stopped
+	})
+}
+
+func (b *Bar) run() {
+	defer close(b.stopped)
+
+	interval := logInterval
+	if b.tty {
+		interval = ttyInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		select {
+		case <-b.stop:
+			b.render(frame, true)
+			return
+		case <-ticker.C:
+			b.render(frame, false)
+		}
+	}
+}
+
+func (b *Bar) render(frame int, final bool) {
+	now := time.Now()
+	done := b.done.Load()
+
+	b.samples = append(b.samples, sample{now, done})
+	for len(b.samples) > 2 && now.Sub(b.samples[0].at) > window {
+		b.samples = b.samples[1:]
+	}
+
+	rate := 0.0
+	if first := b.samples[0]; now.After(first.at) && done > first.done {
+		rate = float64(done-first.done) / now.Sub(first.at).Seconds()
+	}
+	if final {
+		// Report the overall average once finished
+		if elapsed := now.Sub(b.start).Seconds(); elapsed > 0 {
+			rate = float64(done) / elapsed
+		}
+	}
+
+	var line strings.Builder
+	if b.total > 0 {
+		pct := float64(done) / float64(b.total)
+		if pct > 1 {
+			pct = 1
+		}
+		if b.tty {
+			filled := int(pct * barWidth)
+			fmt.Fprintf(&line, "%s %s [%s%s] ", spinners[frame%len(spinners)], b.label,
+				strings.Repeat("█", filled), strings.Repeat("░", barWidth-filled))
+		} else {
+			fmt.Fprintf(&line, "%s: ", b.label)
+		}
+		fmt.Fprintf(&line, "%3.0f%%  %s / %s  %s/s", pct*100, formatBytes(done), formatBytes(b.total), formatBytes(int64(rate)))
+		if !final && rate > 0 && done < b.total {
+			eta := time.Duration(float64(b.total-done)/rate) * time.Second
+			fmt.Fprintf(&line, "  ETA %s", eta.Round(time.Second))
+		}
+	} else {
+		if b.tty {
+			fmt.Fprintf(&line, "%s %s ", spinners[frame%len(spinners)], b.label)
+		} else {
+			fmt.Fprintf(&line, "%s: ", b.label)
+		}
+		fmt.Fprintf(&line, "%s  %s/s", formatBytes(done), formatBytes(int64(rate)))
+	}
+	if final {
+		fmt.Fprintf(&line, "  in %s", now.Sub(b.start).Round(time.Second))
+	}
+
+	if b.tty {
+		// Pad to clear leftovers from a longer previous line
+		fmt.Fprintf(b.out, "\r%-90s", line.String())
+		if final {
+			fmt.Fprintln(b.out)
+		}
+		return
+	}
+	fmt.Fprintln(b.out, line.String())
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
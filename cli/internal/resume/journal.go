@@ -0,0 +1,228 @@
+package resume
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/api"
+	"github.com/bucketlabs-dot-org/bucket/cli/internal/config"
+)
+
+// Bytes hashed from the head and tail of a file for its fingerprint
+const sampleSize = 1 << 20
+
+// Journal records an in-flight push so it can be picked up again after a
+// crash, Ctrl-C or lost network. It lives under <config dir>/uploads and is
+// removed once the server has verified the upload.
+type Journal struct {
+	LocalPath   string `json:"local_path"`
+	Fingerprint string `json:"fingerprint"`
+
+	FileID    string `json:"file_id"`
+	UploadID  string `json:"upload_id,omitempty"`
+	TinyCode  string `json:"tiny_code"`
+	Secret    string `json:"secret"` // Only returned once by the server
+	ExpiresAt string `json:"expires_at"`
+
+	// Client-side encryption key, if --encrypt. It goes to the credential
+	// store as KeyRef; only when API keys are kept in config.json too is it
+	// written here in the clear, in a file only the user can read.
+	Key    string `json:"key,omitempty"`
+	KeyRef string `json:"key_ref,omitempty"`
+
+	Options   Options             `json:"options"`
+	Completed []api.CompletedPart `json:"completed"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// Options are the push flags that shape the stored file. A resume with
+// different ones would silently get the first attempt's, so it starts
+// over instead.
+type Options struct {
+	Name         string   `json:"name"`
+	Expires      string   `json:"expires,omitempty"` // As given to --expires, so "2h" still matches
+	MaxDownloads int      `json:"max_downloads,omitempty"`
+	Burn         bool     `json:"burn,omitempty"`
+	Recipients   []string `json:"recipients,omitempty"`
+}
+
+// Diff names the options that differ from o, nil if none do
+func (o Options) Diff(other Options) []string {
+	var changed []string
+	if o.Name != other.Name {
+		changed = append(changed, "--name")
+	}
+	if o.Expires != other.Expires {
+		changed = append(changed, "--expires")
+	}
+	if o.MaxDownloads != other.MaxDownloads {
+		changed = append(changed, "--max-downloads")
+	}
+	if o.Burn != other.Burn {
+		changed = append(changed, "--burn")
+	}
+	if strings.Join(o.Recipients, "\n") != strings.Join(other.Recipients, "\n") {
+		changed = append(changed, "--to")
+	}
+	return changed
+}
+
+func journalDir() string {
+	return filepath.Join(config.Dir(), "uploads")
+}
+
+func journalPath(localPath string) string {
+	sum := sha256.Sum256([]byte(localPath))
+	return filepath.Join(journalDir(), hex.EncodeToString(sum[:8])+".json")
+}
+
+// Fingerprint identifies the contents of a local file cheaply: size, mtime
+// and a hash of the first and last MiB. A resumed push is refused if this
+// no longer matches the journal.
+func Fingerprint(localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%d:", stat.Size(), stat.ModTime().UnixNano())
+
+	if _, err := io.CopyN(h, f, sampleSize); err != nil && err != io.EOF {
+		return "", err
+	}
+	if stat.Size() > sampleSize {
+		tail := io.NewSectionReader(f, stat.Size()-sampleSize, sampleSize)
+		if _, err := io.Copy(h, tail); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Load returns the journal for localPath, or nil if there is none
+func Load(localPath string) (*Journal, error) {
+	path := journalPath(localPath)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	j.path = path
+	return &j, nil
+}
+
+// New starts a journal for a freshly requested upload
+func New(localPath, fingerprint string, opts Options, init *api.UploadInitResponse) *Journal {
+	return &Journal{
+		LocalPath:   localPath,
+		Fingerprint: fingerprint,
+		Options:     opts,
+		FileID:      init.FileID,
+		UploadID:    init.UploadID,
+		TinyCode:    init.TinyCode,
+		Secret:      init.Secret,
+		ExpiresAt:   init.ExpiresAt,
+		path:        journalPath(localPath),
+	}
+}
+
+// Encrypted reports whether the upload was encrypted client-side
+func (j *Journal) Encrypted() bool {
+	return j.Key != "" || j.KeyRef != ""
+}
+
+// SetKey records the upload's encryption key, in the credential store when
+// there is one
+func (j *Journal) SetKey(key string) error {
+	account := "upload/" + strings.TrimSuffix(filepath.Base(j.path), ".json")
+	ref, err := config.StoreSecret(account, key)
+	if err != nil {
+		return err
+	}
+	if ref == "" {
+		j.Key = key
+		return nil
+	}
+	j.KeyRef = ref
+	return nil
+}
+
+// LoadKey returns the key recorded by SetKey
+func (j *Journal) LoadKey() (string, error) {
+	if j.KeyRef == "" {
+		return j.Key, nil
+	}
+	return config.LoadSecret(j.KeyRef)
+}
+
+// Record marks a part as confirmed by storage and persists the journal.
+// Safe for concurrent use by upload workers.
+func (j *Journal) Record(part api.CompletedPart) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.Completed = append(j.Completed, part)
+	return j.save()
+}
+
+func (j *Journal) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.save()
+}
+
+func (j *Journal) save() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write then rename so a crash mid-save never leaves a torn journal
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// Remove deletes the journal once the upload is finished or abandoned
+func (j *Journal) Remove() error {
+	if j.KeyRef != "" {
+		config.DeleteSecret(j.KeyRef)
+	}
+	err := os.Remove(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}